@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	config "github.com/TimiBolu/owl-db/owl-db-config"
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+)
+
+// dumpCommand writes collections to a directory in the layout of mongodump.
+func dumpCommand(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	dir := flags.String("dir", config.DatabasePath, "database directory")
+	out := flags.String("out", "dump", "directory to write <collection>.bson and <collection>.metadata.json to")
+	collections := flags.String("collections", "", "comma-separated collections to dump, all if empty")
+	flags.Parse(args)
+
+	db, err := openDatabase(*dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.Dump(*out, core.DumpOptions{Collections: splitList(*collections)}); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "dumped to %s; restore into MongoDB with mongorestore --db <name> %s\n", *out, *out)
+	return nil
+}
+
+// loadCommand inserts the collections of a mongodump directory.
+func loadCommand(args []string) error {
+	flags := flag.NewFlagSet("load", flag.ExitOnError)
+	dir := flags.String("dir", config.DatabasePath, "database directory")
+	in := flags.String("in", "dump", "directory of one database's .bson and .metadata.json files")
+	collections := flags.String("collections", "", "comma-separated collections to load, all if empty")
+	flags.Parse(args)
+
+	db, err := openDatabase(*dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.LoadDump(*in, core.LoadDumpOptions{Collections: splitList(*collections)})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	config "github.com/TimiBolu/owl-db/owl-db-config"
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+)
+
+// exportCommand writes a collection as JSON, Extended JSON or CSV, in the
+// formats of mongoexport, or as BSON.
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := flags.String("dir", config.DatabasePath, "database directory")
+	collection := flags.String("collection", "", "collection to export")
+	formatName := flags.String("format", "relaxed", "json, relaxed or canonical Extended JSON, csv or bson")
+	fields := flags.String("fields", "", "comma-separated fields, the columns of csv")
+	out := flags.String("out", "-", "output file, - for stdout")
+	flags.Parse(args)
+
+	format, err := core.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase(*dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	c, err := existingCollection(db, *collection)
+	if err != nil {
+		return err
+	}
+
+	file := os.Stdout
+	if *out != "-" {
+		if file, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer file.Close()
+	}
+
+	count, err := c.Export(file, format, core.ExportOptions{Fields: splitList(*fields)})
+	if err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil && file != os.Stdout {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d documents from %s\n", count, *collection)
+	return nil
+}
+
+// importCommand inserts the documents of a mongoimport-style file into a
+// collection, creating it if needed.
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := flags.String("dir", config.DatabasePath, "database directory")
+	collection := flags.String("collection", "", "collection to import into")
+	formatName := flags.String("format", "relaxed", "json, relaxed or canonical Extended JSON, csv or bson")
+	fields := flags.String("fields", "", "comma-separated csv columns, such as name,price.double(); read from the first line if empty")
+	in := flags.String("in", "-", "input file, - for stdin")
+	flags.Parse(args)
+
+	if *collection == "" {
+		return errors.New("-collection is required")
+	}
+	format, err := core.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase(*dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	file := os.Stdin
+	if *in != "-" {
+		if file, err = os.Open(*in); err != nil {
+			return err
+		}
+		defer file.Close()
+	}
+
+	c := core.NewCollection[*core.RawDocument](db, *collection)
+	result, err := c.Import(file, format, core.ImportOptions{Fields: splitList(*fields)})
+	fmt.Fprintf(os.Stderr, "imported %d documents into %s\n", result.Inserted, *collection)
+	return err
+}
+
+// existingCollection opens a collection for reading, failing rather than
+// creating it if it doesn't exist.
+func existingCollection(db *core.DB, name string) (*core.Collection[*core.RawDocument], error) {
+	if name == "" {
+		return nil, errors.New("-collection is required")
+	}
+	for _, existing := range db.CollectionNames() {
+		if existing == name {
+			return core.NewCollection[*core.RawDocument](db, name), nil
+		}
+	}
+	return nil, fmt.Errorf("no collection named %s", name)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	config "github.com/TimiBolu/owl-db/owl-db-config"
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+)
+
+// rekeyCommand rotates the master encryption key of a closed database.
+// Without -old-key-file it encrypts a database that was not encrypted.
+func rekeyCommand(args []string) error {
+	flags := flag.NewFlagSet("rekey", flag.ExitOnError)
+	dir := flags.String("dir", config.DatabasePath, "database directory")
+	oldKeyFile := flags.String("old-key-file", "", "file holding the current key, if the database is encrypted")
+	newKeyFile := flags.String("new-key-file", "", "file holding the new key (16, 24 or 32 bytes)")
+	flags.Parse(args)
+
+	if *newKeyFile == "" {
+		return errors.New("-new-key-file is required")
+	}
+
+	var oldKey []byte
+	if *oldKeyFile != "" {
+		var err error
+		oldKey, err = config.ReadKeyFile(*oldKeyFile)
+		if err != nil {
+			return err
+		}
+	}
+	newKey, err := config.ReadKeyFile(*newKeyFile)
+	if err != nil {
+		return err
+	}
+
+	if err := core.Rekey(*dir, oldKey, newKey); err != nil {
+		return err
+	}
+	fmt.Printf("rekeyed %s; open it with %s=%s\n", *dir, config.EncryptionKeyFileEnv, *newKeyFile)
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	config "github.com/TimiBolu/owl-db/owl-db-config"
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+)
+
+// commands are the subcommands of the owl-db binary, run as
+// `owl-db <command> [flags]`.
+var commands = map[string]struct {
+	summary string
+	run     func(args []string) error
+}{
+	"rekey":   {"re-encrypt a closed database with a new key", rekeyCommand},
+	"backup":  {"write a full or incremental backup", backupCommand},
+	"restore": {"load a backup", restoreCommand},
+	"export":  {"write a collection as JSON, Extended JSON or CSV", exportCommand},
+	"import":  {"insert documents from JSON, Extended JSON or CSV", importCommand},
+	"dump":    {"write collections in the format of mongodump", dumpCommand},
+	"load":    {"load collections from a mongodump directory", loadCommand},
+}
+
+// runCommand runs the named subcommand and exits the process if it fails.
+func runCommand(name string, args []string) {
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage())
+		os.Exit(2)
+	}
+
+	if err := command.run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "owl-db %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// openDatabase opens the database in dir with the options from the
+// environment, such as its encryption key.
+func openDatabase(dir string) (*core.DB, error) {
+	options, err := config.DatabaseOptions()
+	if err != nil {
+		return nil, err
+	}
+	options.Logger = quietLogger{}
+	return core.Open(dir, options)
+}
+
+// splitList splits a comma-separated flag value, nil if it is empty.
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// quietLogger keeps Badger's progress messages out of the commands' output.
+type quietLogger struct{}
+
+func (quietLogger) Errorf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "badger: "+format, args...)
+}
+
+func (quietLogger) Warningf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "badger: "+format, args...)
+}
+
+func (quietLogger) Infof(string, ...interface{})  {}
+func (quietLogger) Debugf(string, ...interface{}) {}
+
+func usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Usage: owl-db <command> [flags]\n\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-10s %s\n", name, commands[name].summary)
+	}
+	return b.String()
+}
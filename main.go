@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"os"
 	"time"
 
 	config "github.com/TimiBolu/owl-db/owl-db-config"
@@ -64,17 +66,30 @@ func (o *Order) SetUpdatedAt() {
 }
 
 func main() {
-	config.InitServer()
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	db, err := config.InitServer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := config.TerminateServer(db); err != nil {
+			log.Printf("closing the database: %v", err)
+		}
+	}()
 
 	productCollection := core.NewCollection[*Product](
-		config.BadgerDBClient,
+		db,
 		"products",
 		core.CollectionOptions{
 			Timestamp: true,
 		},
 	)
 	// orderCollection := core.NewCollection[*Order](
-	// 	config.BadgerDBClient,
+	// 	db,
 	// 	"orders",
 	// )
 
@@ -110,12 +125,12 @@ func main() {
 	// 	}
 	// }
 
-	// err := productCollection.InsertMany(productList)
+	// _, err := productCollection.InsertMany(productList)
 	// if err != nil {
 	// 	fmt.Println(err)
 	// }
 
-	// err := productCollection.UpdateMany(
+	// _, err := productCollection.UpdateMany(
 	// 	core.Filter{
 	// 		"price": 2137.0,
 	// 	},
@@ -252,8 +267,6 @@ func main() {
 	// 	fmt.Println(err)
 	// }
 	// fmt.Println(order)
-
-	defer config.TerminateServer()
 }
 
 type Person struct {
@@ -1,25 +1,52 @@
 package config
 
 import (
-	"log"
+	"fmt"
+	"os"
 
-	badger "github.com/dgraph-io/badger/v4"
+	core "github.com/TimiBolu/owl-db/owl-db-core"
 )
 
-var BadgerDBClient *badger.DB
+// DatabasePath is where the server keeps its data
+const DatabasePath = "./badger"
 
-func ConnectBadgerDB() {
-	opts := badger.DefaultOptions("./badger")
-	db, err := badger.Open(opts)
+// EncryptionKeyFileEnv names the environment variable holding the path of
+// the database's encryption key file. The database is not encrypted if it is
+// unset.
+const EncryptionKeyFileEnv = "OWL_DB_ENCRYPTION_KEY_FILE"
+
+func ConnectBadgerDB() (*core.DB, error) {
+	options, err := DatabaseOptions()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	return core.Open(DatabasePath, options)
+}
+
+func DisconnectBadgerDB(db *core.DB) error {
+	return db.Close()
+}
+
+// DatabaseOptions returns the options the database is opened with, taken
+// from the environment.
+func DatabaseOptions() (core.Options, error) {
+	var options core.Options
+
+	if path := os.Getenv(EncryptionKeyFileEnv); path != "" {
+		key, err := ReadKeyFile(path)
+		if err != nil {
+			return options, err
+		}
+		options.EncryptionKey = key
 	}
-	BadgerDBClient = db
+	return options, nil
 }
 
-func DisconnectBadgerDB() {
-	err := BadgerDBClient.Close()
+// ReadKeyFile reads an encryption key stored as raw bytes.
+func ReadKeyFile(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to read key file: %w", err)
 	}
+	return key, nil
 }
@@ -1,9 +1,13 @@
 package config
 
-func InitServer() {
-	ConnectBadgerDB()
+import (
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+)
+
+func InitServer() (*core.DB, error) {
+	return ConnectBadgerDB()
 }
 
-func TerminateServer() {
-	DisconnectBadgerDB()
+func TerminateServer(db *core.DB) error {
+	return DisconnectBadgerDB(db)
 }
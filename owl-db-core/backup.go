@@ -0,0 +1,305 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// maxPendingRestoreWrites bounds the batches Badger writes concurrently
+// while restoring
+const maxPendingRestoreWrites = 256
+
+// badgerDeleteBit marks a deletion in the Meta of a backed up entry
+const badgerDeleteBit = 1 << 0
+
+type BackupOptions struct {
+	Collections []string // Back up only these collections, every collection if empty
+}
+
+type RestoreOptions struct {
+	Collections []string // Restore only these collections, every collection in the backup if empty
+}
+
+// Backup writes the database to w in Badger's backup format, starting with
+// the catalog and followed by every entry written after the version since,
+// or everything if since is 0. It returns the highest version backed up, or
+// since if nothing changed: pass it as since to take the next incremental
+// backup.
+//
+// Collections are stored under their catalog IDs, so the catalog is always
+// backed up in full to let Restore match them by name.
+func (db *DB) Backup(w io.Writer, since uint64, opts ...BackupOptions) (uint64, error) {
+	var options BackupOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	names, ids, err := db.selectCollections(options.Collections)
+	if err != nil {
+		return 0, err
+	}
+
+	catalog := db.Badger.NewStream()
+	catalog.LogPrefix = "owl-db catalog backup"
+	catalog.Prefix = []byte(catalogPrefix)
+	catalog.ChooseKey = func(item *badger.Item) bool {
+		return names == nil || names[string(item.Key()[len(catalogPrefix):])]
+	}
+	if _, err := catalog.Backup(w, 0); err != nil {
+		return 0, fmt.Errorf("failed to back up catalog: %w", err)
+	}
+
+	data := db.Badger.NewStream()
+	data.LogPrefix = "owl-db backup"
+	data.SinceTs = since
+	data.ChooseKey = func(item *badger.Item) bool {
+		id, ok := keyCollectionID(item.Key())
+		return ok && (ids == nil || ids[id])
+	}
+	upto, err := data.Backup(w, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to back up data: %w", err)
+	}
+	return max(upto, since), nil
+}
+
+// Restore loads a backup written by Backup. Collections are matched by name:
+// the ones that don't exist yet are created, and the entries of the others
+// are written into them. It must not run concurrently with other writes.
+func (db *DB) Restore(r io.Reader, opts ...RestoreOptions) error {
+	var options RestoreOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	var names map[string]bool
+	if len(options.Collections) > 0 {
+		names = make(map[string]bool)
+		for _, name := range options.Collections {
+			names[name] = true
+		}
+	}
+
+	// Badger loads the rewritten stream so that the entries keep their
+	// versions
+	pr, pw := io.Pipe()
+	rewritten := make(chan error, 1)
+	go func() {
+		err := db.rewriteBackup(r, pw, names)
+		pw.CloseWithError(err)
+		rewritten <- err
+	}()
+
+	err := db.Badger.Load(pr, maxPendingRestoreWrites)
+	pr.CloseWithError(err) // Unblock the rewriting if loading failed
+	if rewriteErr := <-rewritten; rewriteErr != nil {
+		return fmt.Errorf("failed to read backup: %w", rewriteErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load backup: %w", err)
+	}
+	return db.loadReferenceRules()
+}
+
+// selectCollections returns the names and IDs of the given collections, or
+// nil maps if none are given.
+func (db *DB) selectCollections(collections []string) (map[string]bool, map[uint64]bool, error) {
+	if len(collections) == 0 {
+		return nil, nil, nil
+	}
+
+	db.catalogMu.Lock()
+	defer db.catalogMu.Unlock()
+
+	names := make(map[string]bool)
+	ids := make(map[uint64]bool)
+	for _, name := range collections {
+		id, ok := db.catalog[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("collection %s does not exist", name)
+		}
+		names[name] = true
+		ids[id] = true
+	}
+	return names, ids, nil
+}
+
+// rewriteBackup copies a backup stream from r to w, dropping the catalog and
+// moving the entries of each backed up collection to the ID the collection
+// has in db. Only the named collections are kept unless names is nil.
+func (db *DB) rewriteBackup(r io.Reader, w io.Writer, names map[string]bool) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+
+	// The catalog comes first: collect it until the first data entry
+	catalog := make(map[string]uint64)
+	seen := make(map[string]bool)
+	var ids map[uint64]uint64
+
+	for {
+		list, err := readKVList(br)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		out := list.Kv[:0]
+		for _, kv := range list.Kv {
+			key := string(kv.Key)
+			switch {
+			case key == sequenceKey:
+				continue
+			case bytes.HasPrefix(kv.Key, []byte(catalogPrefix)):
+				// Entries of a key come newest first
+				if ids != nil || seen[key] {
+					continue
+				}
+				seen[key] = true
+				if len(kv.Meta) > 0 && kv.Meta[0]&badgerDeleteBit != 0 || len(kv.Value) != 8 {
+					continue // Renamed or dropped
+				}
+				catalog[key[len(catalogPrefix):]] = binary.BigEndian.Uint64(kv.Value)
+				continue
+			}
+
+			if ids == nil {
+				ids, err = db.mapCollectionIDs(catalog, names)
+				if err != nil {
+					return err
+				}
+			}
+			if kv.Key = remapKey(kv.Key, ids); kv.Key != nil {
+				out = append(out, kv)
+			}
+		}
+
+		if len(out) > 0 {
+			list.Kv = out
+			if err := writeKVList(bw, list); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ids == nil {
+		// A backup without data still creates its collections
+		if _, err := db.mapCollectionIDs(catalog, names); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// mapCollectionIDs maps the IDs of the backed up collections to their IDs in
+// db, registering the ones db doesn't have.
+func (db *DB) mapCollectionIDs(catalog map[string]uint64, names map[string]bool) (map[uint64]uint64, error) {
+	ids := make(map[uint64]uint64)
+	for name, backupID := range catalog {
+		if names != nil && !names[name] {
+			continue
+		}
+		id, err := db.collectionID(name)
+		if err != nil {
+			return nil, err
+		}
+		ids[backupID] = id
+	}
+	return ids, nil
+}
+
+// keyCollectionID returns the ID of the collection a data key belongs to.
+func keyCollectionID(key []byte) (uint64, bool) {
+	for _, prefix := range []string{nodePrefix, idxPrefix, edgePrefix, refPrefix, historyPrefix, referenceRulePrefix} {
+		if bytes.HasPrefix(key, []byte(prefix)) && len(key) >= len(prefix)+8 {
+			return binary.BigEndian.Uint64(key[len(prefix):]), true
+		}
+	}
+	return 0, false
+}
+
+// remapKey rewrites the collection IDs in a data key, or returns nil if the
+// key belongs to a collection that is not restored. Reverse references and
+// reference rules also hold the ID of the referencing collection.
+func remapKey(key []byte, ids map[uint64]uint64) []byte {
+	backupID, ok := keyCollectionID(key)
+	if !ok {
+		return nil
+	}
+	id, ok := ids[backupID]
+	if !ok {
+		return nil
+	}
+
+	// Every data prefix is two bytes long
+	remapped := bytes.Clone(key)
+	binary.BigEndian.PutUint64(remapped[2:], id)
+
+	if bytes.HasPrefix(key, []byte(refPrefix)) {
+		// r:<target id><targetID>\x00<source id>...
+		rest := remapped[len(refPrefix)+8:]
+		end := bytes.Index(rest, []byte(keySeparator))
+		if end < 0 || len(rest) < end+1+8 {
+			return nil
+		}
+		sourceID, ok := ids[binary.BigEndian.Uint64(rest[end+1:])]
+		if !ok {
+			return nil
+		}
+		binary.BigEndian.PutUint64(rest[end+1:], sourceID)
+	}
+	if bytes.HasPrefix(key, []byte(referenceRulePrefix)) {
+		// f:<target id><source id><field>
+		source := remapped[len(referenceRulePrefix)+8:]
+		if len(source) < 8 {
+			return nil
+		}
+		sourceID, ok := ids[binary.BigEndian.Uint64(source)]
+		if !ok {
+			return nil
+		}
+		binary.BigEndian.PutUint64(source, sourceID)
+	}
+	return remapped
+}
+
+// readKVList reads one length-prefixed list of entries of a Badger backup.
+func readKVList(r io.Reader) (*pb.KVList, error) {
+	var size uint64
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err // io.EOF at the end of the backup
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	list := &pb.KVList{}
+	if err := proto.Unmarshal(buf, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// writeKVList writes a list of entries in Badger's backup format.
+func writeKVList(w io.Writer, list *pb.KVList) error {
+	buf, err := proto.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(len(buf))); err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
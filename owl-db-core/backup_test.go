@@ -0,0 +1,140 @@
+package core_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+// storedIDs returns the sorted IDs of the documents in c.
+func storedIDs[T core.Document](t *testing.T, c *core.Collection[T]) string {
+	t.Helper()
+
+	docs, err := c.Find(core.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, doc := range docs {
+		ids = append(ids, doc["_id"].(string))
+	}
+	sort.Strings(ids)
+	return fmt.Sprint(ids)
+}
+
+func TestBackupIncrementalRestore(t *testing.T) {
+	src := testutil.OpenDB(t)
+	products := core.NewCollection[*product](src, "products")
+	orders := core.NewCollection[*order](src, "orders", core.CollectionOptions{References: []core.Reference{
+		{Field: "productId", Collection: "products", OnDelete: core.Restrict},
+	}})
+	if _, err := products.InsertMany([]*product{{ID: "p1", Name: "lamp"}, {ID: "p2", Name: "desk"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := orders.Insert(&order{ID: "o1", ProductID: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var full bytes.Buffer
+	since, err := src.Backup(&full, 0)
+	if err != nil {
+		t.Fatalf("full Backup: %v", err)
+	}
+
+	if err := products.Insert(&product{ID: "p3", Name: "chair"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := products.DeleteByID("p2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := products.UpdateByID("p1", core.Update{"$set": map[string]interface{}{"name": "desk lamp"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var incremental bytes.Buffer
+	upto, err := src.Backup(&incremental, since)
+	if err != nil {
+		t.Fatalf("incremental Backup: %v", err)
+	}
+	if upto <= since {
+		t.Errorf("incremental Backup returned version %d, want more than %d", upto, since)
+	}
+	if unchanged, err := src.Backup(&bytes.Buffer{}, upto); err != nil || unchanged != upto {
+		t.Errorf("Backup without changes = %d, %v, want %d", unchanged, err, upto)
+	}
+
+	// Collections created in another order get other IDs
+	dst := testutil.OpenDB(t)
+	if err := core.NewCollection[*product](dst, "suppliers").Insert(&product{ID: "s1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.NewCollection[*order](dst, "orders").Insert(&order{ID: "o2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Restore(&full); err != nil {
+		t.Fatalf("Restore full backup: %v", err)
+	}
+	if err := dst.Restore(&incremental); err != nil {
+		t.Fatalf("Restore incremental backup: %v", err)
+	}
+
+	restored := core.NewCollection[*product](dst, "products")
+	if got := storedIDs(t, restored); got != "[p1 p3]" {
+		t.Errorf("restored products %s, want [p1 p3]", got)
+	}
+	if doc, err := restored.FindByID("p1"); err != nil || doc["name"] != "desk lamp" {
+		t.Errorf("restored p1 = %v, %v, want the desk lamp", doc, err)
+	}
+	if got := storedIDs(t, core.NewCollection[*order](dst, "orders")); got != "[o1 o2]" {
+		t.Errorf("restored orders %s, want [o1 o2]", got)
+	}
+	if got := storedIDs(t, core.NewCollection[*product](dst, "suppliers")); got != "[s1]" {
+		t.Errorf("suppliers %s, want [s1] untouched", got)
+	}
+	if err := restored.DeleteByID("p1"); !errors.Is(err, core.ErrReferenced) {
+		t.Errorf("deleting a referenced product after restoring = %v, want ErrReferenced", err)
+	}
+}
+
+func TestBackupRestoreSelectedCollections(t *testing.T) {
+	src := testutil.OpenDB(t)
+	if err := core.NewCollection[*product](src, "products").Insert(&product{ID: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.NewCollection[*order](src, "orders").Insert(&order{ID: "o1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var all, productsOnly bytes.Buffer
+	if _, err := src.Backup(&all, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Backup(&productsOnly, 0, core.BackupOptions{Collections: []string{"products"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		backup  *bytes.Buffer
+		options core.RestoreOptions
+	}{
+		{"backup of products", &productsOnly, core.RestoreOptions{}},
+		{"restore of products", &all, core.RestoreOptions{Collections: []string{"products"}}},
+	} {
+		dst := testutil.OpenDB(t)
+		if err := dst.Restore(test.backup, test.options); err != nil {
+			t.Fatalf("%s: Restore: %v", test.name, err)
+		}
+		if got := storedIDs(t, core.NewCollection[*product](dst, "products")); got != "[p1]" {
+			t.Errorf("%s: products %s, want [p1]", test.name, got)
+		}
+		if got := storedIDs(t, core.NewCollection[*order](dst, "orders")); got != "[]" {
+			t.Errorf("%s: orders %s, want none", test.name, got)
+		}
+	}
+}
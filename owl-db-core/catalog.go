@@ -0,0 +1,270 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// The catalog maps collection names to the numeric IDs that prefix their
+// keys, so renaming a collection never touches its data and no name can
+// produce keys that collide with another collection's.
+const (
+	catalogPrefix       = "c:"           // catalogPrefix + name -> 8-byte collection ID
+	sequenceKey         = "s:collection" // Last collection ID handed out
+	referenceRulePrefix = "f:"           // referenceRulePrefix + target ID + source ID + field -> OnDelete
+)
+
+// ErrLegacyKeyLayout is returned by Open for a database written before
+// collections had catalog IDs, when documents were keyed <collection>|<docID>.
+// Its collections would look empty, so it is not opened.
+var ErrLegacyKeyLayout = errors.New("database uses the key layout from before the collection catalog")
+
+// layoutPrefixes are the prefixes of every key owl-db writes.
+var layoutPrefixes = []string{catalogPrefix, "s:", referenceRulePrefix, nodePrefix, idxPrefix, edgePrefix, refPrefix, historyPrefix}
+
+// checkKeyLayout fails with ErrLegacyKeyLayout if the database has no
+// catalog but holds <collection>|... keys without a layout prefix, as
+// databases written before the catalog do. Databases with a catalog are
+// never scanned.
+func (db *DB) checkKeyLayout() error {
+	if len(db.catalog) > 0 {
+		return nil
+	}
+
+	return db.Badger.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			key := string(iter.Item().Key())
+			known := false
+			for _, prefix := range layoutPrefixes {
+				if strings.HasPrefix(key, prefix) {
+					known = true
+					break
+				}
+			}
+			if !known && strings.Contains(key, "|") {
+				return ErrLegacyKeyLayout
+			}
+		}
+		return nil
+	})
+}
+
+// loadCatalog reads every catalog entry into memory.
+func (db *DB) loadCatalog() error {
+	return db.Badger.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		prefix := []byte(catalogPrefix)
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			item := iter.Item()
+			name := string(item.Key()[len(prefix):])
+			err := item.Value(func(val []byte) error {
+				if len(val) != 8 {
+					return fmt.Errorf("corrupt catalog entry for collection %s", name)
+				}
+				db.catalog[name] = binary.BigEndian.Uint64(val)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// collectionID returns the ID of the named collection, registering the
+// collection in the catalog if it is new.
+func (db *DB) collectionID(name string) (uint64, error) {
+	if name == "" {
+		return 0, errors.New("collection name must not be empty")
+	}
+
+	db.catalogMu.Lock()
+	defer db.catalogMu.Unlock()
+
+	if id, ok := db.catalog[name]; ok {
+		return id, nil
+	}
+
+	var id uint64
+	err := withRetry(context.Background(), DefaultRetryOptions, func() error {
+		return db.Badger.Update(func(txn *badger.Txn) error {
+			var err error
+			id, err = nextCollectionID(txn)
+			if err != nil {
+				return err
+			}
+			return txn.Set(catalogKey(name), encodeCollectionID(id))
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to register collection %s: %w", name, err)
+	}
+
+	db.catalog[name] = id
+	return id, nil
+}
+
+// CollectionNames returns the names of the collections in the catalog,
+// sorted.
+func (db *DB) CollectionNames() []string {
+	db.catalogMu.Lock()
+	defer db.catalogMu.Unlock()
+
+	names := make([]string, 0, len(db.catalog))
+	for name := range db.catalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nextCollectionID advances the collection ID sequence in txn.
+func nextCollectionID(txn *badger.Txn) (uint64, error) {
+	var last uint64
+	item, err := txn.Get([]byte(sequenceKey))
+	switch {
+	case errors.Is(err, badger.ErrKeyNotFound):
+	case err != nil:
+		return 0, err
+	default:
+		err = item.Value(func(val []byte) error {
+			last = binary.BigEndian.Uint64(val)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	id := last + 1
+	return id, txn.Set([]byte(sequenceKey), encodeCollectionID(id))
+}
+
+func catalogKey(name string) []byte {
+	return []byte(catalogPrefix + name)
+}
+
+func encodeCollectionID(id uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, id)
+}
+
+// collectionPrefix is a key prefix followed by a collection ID.
+func collectionPrefix(prefix string, id uint64) string {
+	return prefix + string(encodeCollectionID(id))
+}
+
+// RenameCollection renames a collection in the catalog. Its data is keyed by
+// collection ID, so nothing else is rewritten. Handles created before the
+// rename keep working but report the old Name.
+func (db *DB) RenameCollection(oldName, newName string) error {
+	if newName == "" {
+		return errors.New("collection name must not be empty")
+	}
+
+	db.catalogMu.Lock()
+	defer db.catalogMu.Unlock()
+
+	var id uint64
+	err := withRetry(context.Background(), DefaultRetryOptions, func() error {
+		return db.Badger.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get(catalogKey(oldName))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return fmt.Errorf("collection %s does not exist", oldName)
+			}
+			if err != nil {
+				return err
+			}
+			idBytes, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			id = binary.BigEndian.Uint64(idBytes)
+
+			_, err = txn.Get(catalogKey(newName))
+			if err == nil {
+				return fmt.Errorf("collection %s already exists", newName)
+			}
+			if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+
+			if err := txn.Delete(catalogKey(oldName)); err != nil {
+				return err
+			}
+			return txn.Set(catalogKey(newName), idBytes)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	delete(db.catalog, oldName)
+	db.catalog[newName] = id
+	return nil
+}
+
+// removeFromCatalog deletes the catalog entry of a collection being dropped,
+// under whatever name it has now, together with the rules of the references
+// from and to it.
+func (db *DB) removeFromCatalog(id uint64) error {
+	db.catalogMu.Lock()
+	defer db.catalogMu.Unlock()
+	db.referencesMu.Lock()
+	defer db.referencesMu.Unlock()
+
+	name, found := "", false
+	for catalogName, catalogID := range db.catalog {
+		if catalogID == id {
+			name, found = catalogName, true
+		}
+	}
+	if !found {
+		return ErrCollectionDropped
+	}
+
+	err := withRetry(context.Background(), DefaultRetryOptions, func() error {
+		return db.Badger.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get(catalogKey(name))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return fmt.Errorf("collection %s does not exist", name)
+			}
+			if err != nil {
+				return err
+			}
+			err = item.Value(func(val []byte) error {
+				if binary.BigEndian.Uint64(val) != id {
+					return fmt.Errorf("collection %s was replaced by another collection", name)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if err := deleteReferenceRules(txn, id); err != nil {
+				return err
+			}
+			return txn.Delete(catalogKey(name))
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	delete(db.catalog, name)
+	db.forgetReferenceRules(id)
+	return nil
+}
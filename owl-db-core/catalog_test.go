@@ -0,0 +1,36 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestOpenRefusesLegacyKeyLayout(t *testing.T) {
+	dir := t.TempDir()
+
+	// Documents used to be keyed <collection>|<docID>, with no catalog
+	bdb, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bdb.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("products|p1"), []byte{5, 0, 0, 0, 0})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := core.Open(dir, core.Options{})
+	if err == nil {
+		db.Close()
+	}
+	if !errors.Is(err, core.ErrLegacyKeyLayout) {
+		t.Fatalf("Open error = %v, want ErrLegacyKeyLayout", err)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	utils "github.com/TimiBolu/owl-db/owl-db-utils"
@@ -14,43 +15,120 @@ type Collection[T Document] struct {
 	Name       string
 	Indexes    []string
 	Timestamp  bool
-	EdgeLabels []string
-	edgePrefix string
+	Versioning bool
+	// Number of prior versions of each document kept for History and FindByIDAt
+	KeepVersions  int
+	EdgeLabels    []string
+	References    []Reference
+	Retry         RetryOptions
+	id            uint64 // From the catalog
+	nodePrefix    string
+	idxPrefix     string
+	edgePrefix    string
+	historyPrefix string
+	refTargets    []uint64 // Collection ID of each reference's target
+	db            *DB
+	tx            *Tx       // Set by WithTx
+	snapshot      *Snapshot // Set by WithSnapshot
+	err           error     // Set if the collection could not be registered
 }
 
 // Collection options and configuration
 type CollectionOptions struct {
 	Timestamp bool
 	Indexes   []string
+	// Keep a _v field that every write increments, for optimistic concurrency
+	Versioning bool
+	// Keep this many prior versions of each document, with the time each
+	// was written, for History and FindByIDAt. 0 keeps none.
+	KeepVersions int
 	// Edge specific options
 	EdgeLabels []string
+	// Fields holding IDs of documents in other collections, enforced on delete
+	References []Reference
+	// Retry policy for write conflicts, DefaultRetryOptions if nil
+	Retry *RetryOptions
 }
 
-// Key prefixes for different types of data
+// Key prefixes for different types of data. Each is followed by the 8-byte
+// ID of the collection from the catalog, so a scan over one collection's
+// documents touches nothing else.
 const (
-	nodePrefix = "n:" // Node prefix
-	edgePrefix = "e:" // Edge prefix
-	idxPrefix  = "i:" // Index prefix
+	nodePrefix    = "n:" // Node prefix: n:<id><docID>
+	edgePrefix    = "e:" // Edge prefix: e:<id><direction><nodeID>\x00<label>\x00<otherID>
+	idxPrefix     = "i:" // Index prefix: i:<id><field>\x00<value>\x00<docID>
+	refPrefix     = "r:" // Reverse-reference prefix: r:<target id><targetID>\x00<source id><field>\x00<sourceID>
+	historyPrefix = "h:" // History prefix: h:<id><docID>\x00<8-byte write time in Unix nanoseconds>
 )
 
-func NewCollection[T Document](db *badger.DB, name string, opts ...CollectionOptions) *Collection[T] {
-	var timestamp bool
+// keySeparator ends the variable-length parts of index, edge, reference and
+// history keys other than the last one
+const keySeparator = "\x00"
+
+// NewCollection returns a handle on the named collection of db, registering
+// it in the catalog if it is new. It is a function rather than a DB method
+// because Go methods cannot take type parameters. If the catalog can't be
+// updated, every method of the collection returns the error.
+func NewCollection[T Document](db *DB, name string, opts ...CollectionOptions) *Collection[T] {
+	var timestamp, versioning bool
+	var keepVersions int
 	var indexes, edgeLabels []string
+	var references []Reference
+	retry := DefaultRetryOptions
 
 	if len(opts) > 0 {
 		timestamp = opts[0].Timestamp
+		versioning = opts[0].Versioning
+		keepVersions = max(opts[0].KeepVersions, 0)
 		indexes = utils.RemoveDuplicates(opts[0].Indexes)
 		edgeLabels = utils.RemoveDuplicates(opts[0].EdgeLabels)
+		references = opts[0].References
+		retry = retryOptionsOrDefault(opts[0].Retry)
 	}
 
-	return &Collection[T]{
-		Db:         db,
-		Name:       name,
-		Indexes:    indexes,
-		Timestamp:  timestamp,
-		EdgeLabels: edgeLabels,
-		edgePrefix: fmt.Sprintf("%s%s:", edgePrefix, name),
+	c := &Collection[T]{
+		Db:           db.Badger,
+		Name:         name,
+		Indexes:      indexes,
+		Timestamp:    timestamp,
+		Versioning:   versioning,
+		KeepVersions: keepVersions,
+		EdgeLabels:   edgeLabels,
+		References:   references,
+		Retry:        retry,
+		db:           db,
 	}
+
+	c.id, c.err = db.collectionID(name)
+	if c.err != nil {
+		return c
+	}
+	c.nodePrefix = collectionPrefix(nodePrefix, c.id)
+	c.idxPrefix = collectionPrefix(idxPrefix, c.id)
+	c.edgePrefix = collectionPrefix(edgePrefix, c.id)
+	c.historyPrefix = collectionPrefix(historyPrefix, c.id)
+
+	for _, reference := range references {
+		var target uint64
+		target, c.err = db.collectionID(reference.Collection)
+		if c.err != nil {
+			return c
+		}
+		c.refTargets = append(c.refTargets, target)
+	}
+	c.err = registerReferences(c)
+
+	return c
+}
+
+// docKey is the key of a document of the collection.
+func (c *Collection[T]) docKey(docID string) []byte {
+	return []byte(c.nodePrefix + docID)
+}
+
+// indexKey is the key of an index entry of the collection.
+func (c *Collection[T]) indexKey(field string, value interface{}, docID string) []byte {
+	return []byte(fmt.Sprintf("%s%s%s%v%s%s", c.idxPrefix, field, keySeparator, value, keySeparator, docID))
 }
 
 func getIndexableFields(doc interface{}, indexFields []string) map[string]interface{} {
@@ -83,19 +161,31 @@ func getIndexableFields(doc interface{}, indexFields []string) map[string]interf
 }
 
 // getNestedValue retrieves the value from a BSON map given a list of keys (parts).
+// Numeric keys index into arrays.
 func getNestedValue(docMap map[string]interface{}, keys []string) (interface{}, bool) {
 	var value interface{} = docMap
 
 	for _, key := range keys {
-		if nestedDoc, ok := value.(map[string]interface{}); ok {
-			if val, exists := nestedDoc[key]; exists {
-				value = val
-			} else {
-				return nil, false // Key does not exist
-			}
-		} else {
-			return nil, false // Not a Document, cannot traverse further
+		nested, exists := childValue(value, key)
+		if !exists {
+			return nil, false // Key does not exist, or cannot traverse further
 		}
+		value = nested
 	}
 	return value, true // Successfully found the value
 }
+
+// childValue returns the field of a document or the element of an array at a numeric key.
+func childValue(value interface{}, key string) (interface{}, bool) {
+	if nestedDoc, ok := value.(map[string]interface{}); ok {
+		val, exists := nestedDoc[key]
+		return val, exists
+	}
+
+	if array, ok := toArray(value); ok {
+		if index, err := strconv.Atoi(key); err == nil && index >= 0 && index < len(array) {
+			return array[index], true
+		}
+	}
+	return nil, false
+}
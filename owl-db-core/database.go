@@ -0,0 +1,198 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
+)
+
+// DB is an open owl-db database. Several can be open in one process, each on
+// its own directory.
+type DB struct {
+	Badger *badger.DB // Underlying Badger handle
+
+	// Collection IDs by name, see collectionID
+	catalogMu sync.Mutex
+	catalog   map[string]uint64
+
+	// Reference rules by referenced collection ID, see Reference
+	referencesMu sync.RWMutex
+	references   map[uint64][]referenceRule
+}
+
+// Compression selects the block compression of the Badger tables.
+type Compression int
+
+const (
+	DefaultCompression Compression = iota // Badger's default, Snappy
+	NoCompression
+	SnappyCompression
+	ZSTDCompression
+)
+
+// defaultEncryptedIndexCacheSize is the index cache of encrypted databases
+// that don't set one
+const defaultEncryptedIndexCacheSize = 100 << 20
+
+// Options tunes the Badger database behind a DB. Zero values keep Badger's
+// defaults.
+type Options struct {
+	InMemory         bool          // Keep everything in memory; the path is ignored and nothing is persisted
+	SyncWrites       bool          // Sync every write to disk before it returns
+	ValueLogFileSize int64         // Maximum size in bytes of each value log file
+	BlockCacheSize   int64         // Bytes of block cache, 0 keeps the default
+	IndexCacheSize   int64         // Bytes of index cache, 0 keeps indexes in memory (or 100 MB if encrypted)
+	Compression      Compression   // Table block compression
+	Logger           badger.Logger // Destination of Badger's logs, Badger's default logger if nil
+
+	// AES key of 16, 24 or 32 bytes. When set, data is encrypted at rest with
+	// data keys that Badger keeps in its key registry, encrypted with this key.
+	EncryptionKey []byte
+	// How long a data key encrypts new data before Badger generates the next
+	// one, 10 days if 0
+	EncryptionKeyRotation time.Duration
+}
+
+// Open opens or creates the database in the directory at path, or an empty
+// in-memory database if opts.InMemory is set. Create
+// collections on it with NewCollection:
+//
+//	db, err := core.Open("./data", core.Options{SyncWrites: true})
+//	if err != nil {
+//		return err
+//	}
+//	defer db.Close()
+//
+//	products := core.NewCollection[*Product](db, "products")
+func Open(path string, opts Options) (*DB, error) {
+	badgerOptions, err := opts.badgerOptions(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bdb, err := badger.Open(badgerOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database at %s: %w", path, err)
+	}
+
+	db := &DB{
+		Badger:     bdb,
+		catalog:    make(map[string]uint64),
+		references: make(map[uint64][]referenceRule),
+	}
+	if err := db.loadCatalog(); err != nil {
+		bdb.Close()
+		return nil, fmt.Errorf("failed to load catalog: %w", err)
+	}
+	if err := db.loadReferenceRules(); err != nil {
+		bdb.Close()
+		return nil, fmt.Errorf("failed to load reference rules: %w", err)
+	}
+	if err := db.checkKeyLayout(); err != nil {
+		bdb.Close()
+		return nil, fmt.Errorf("cannot open database at %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Close flushes pending writes and closes the database.
+func (db *DB) Close() error {
+	return db.Badger.Close()
+}
+
+// badgerOptions applies the non-zero options to Badger's defaults.
+func (opts Options) badgerOptions(path string) (badger.Options, error) {
+	badgerOptions := badger.DefaultOptions(path).WithSyncWrites(opts.SyncWrites)
+	if opts.InMemory {
+		badgerOptions = badgerOptions.WithDir("").WithValueDir("").WithInMemory(true)
+	}
+
+	if opts.ValueLogFileSize != 0 {
+		badgerOptions = badgerOptions.WithValueLogFileSize(opts.ValueLogFileSize)
+	}
+	if opts.BlockCacheSize != 0 {
+		badgerOptions = badgerOptions.WithBlockCacheSize(opts.BlockCacheSize)
+	}
+	if opts.IndexCacheSize != 0 {
+		badgerOptions = badgerOptions.WithIndexCacheSize(opts.IndexCacheSize)
+	}
+	if opts.Logger != nil {
+		badgerOptions = badgerOptions.WithLogger(opts.Logger)
+	}
+
+	if len(opts.EncryptionKey) > 0 {
+		if err := checkEncryptionKey(opts.EncryptionKey); err != nil {
+			return badgerOptions, err
+		}
+		badgerOptions = badgerOptions.WithEncryptionKey(opts.EncryptionKey)
+
+		// Badger can't keep the indexes of encrypted tables in memory
+		if opts.IndexCacheSize == 0 {
+			badgerOptions = badgerOptions.WithIndexCacheSize(defaultEncryptedIndexCacheSize)
+		}
+	}
+	if opts.EncryptionKeyRotation != 0 {
+		badgerOptions = badgerOptions.WithEncryptionKeyRotationDuration(opts.EncryptionKeyRotation)
+	}
+
+	switch opts.Compression {
+	case DefaultCompression:
+	case NoCompression:
+		badgerOptions = badgerOptions.WithCompression(options.None)
+	case SnappyCompression:
+		badgerOptions = badgerOptions.WithCompression(options.Snappy)
+	case ZSTDCompression:
+		badgerOptions = badgerOptions.WithCompression(options.ZSTD)
+	default:
+		return badgerOptions, fmt.Errorf("unknown compression %d", opts.Compression)
+	}
+
+	return badgerOptions, nil
+}
+
+// Rekey re-encrypts the key registry of the closed database at path with
+// newKey. Data is encrypted with data keys that the registry stores encrypted
+// with the master key, so rotating the master key rewrites only the
+// registry. An empty oldKey encrypts a plain database: existing data stays
+// readable and everything written afterwards is encrypted. Encryption can't
+// be turned off again, so newKey is required.
+//
+// The database must not be open while it is rekeyed.
+func Rekey(path string, oldKey, newKey []byte) error {
+	if len(oldKey) > 0 {
+		if err := checkEncryptionKey(oldKey); err != nil {
+			return err
+		}
+	}
+	if err := checkEncryptionKey(newKey); err != nil {
+		return err
+	}
+
+	registryOptions := badger.KeyRegistryOptions{
+		Dir:           path,
+		ReadOnly:      true,
+		EncryptionKey: oldKey,
+	}
+	registry, err := badger.OpenKeyRegistry(registryOptions)
+	if err != nil {
+		return fmt.Errorf("failed to open key registry of %s: %w", path, err)
+	}
+	defer registry.Close()
+
+	registryOptions.EncryptionKey = newKey
+	if err := badger.WriteKeyRegistry(registry, registryOptions); err != nil {
+		return fmt.Errorf("failed to write key registry of %s: %w", path, err)
+	}
+	return nil
+}
+
+func checkEncryptionKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+}
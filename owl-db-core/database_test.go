@@ -0,0 +1,82 @@
+package core_test
+
+import (
+	"bytes"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+)
+
+// writeProduct opens the database at dir, stores p1 and closes it again.
+func writeProduct(t *testing.T, dir string, key []byte) {
+	t.Helper()
+
+	db, err := core.Open(dir, core.Options{EncryptionKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := core.NewCollection[*product](db, "products").Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readProduct opens the database at dir and reads p1 back.
+func readProduct(t *testing.T, dir string, key []byte) {
+	t.Helper()
+
+	db, err := core.Open(dir, core.Options{EncryptionKey: key})
+	if err != nil {
+		t.Fatalf("Open after Rekey: %v", err)
+	}
+	defer db.Close()
+
+	doc, err := core.NewCollection[*product](db, "products").FindByID("p1")
+	if err != nil || doc["name"] != "lamp" {
+		t.Errorf("FindByID(p1) after Rekey = %v, %v, want the lamp", doc, err)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	oldKey := bytes.Repeat([]byte("o"), 32)
+	newKey := bytes.Repeat([]byte("n"), 32)
+
+	for _, test := range []struct {
+		name   string
+		oldKey []byte
+	}{
+		{"rotate", oldKey},
+		{"encrypt", nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeProduct(t, dir, test.oldKey)
+
+			if err := core.Rekey(dir, test.oldKey, newKey); err != nil {
+				t.Fatalf("Rekey: %v", err)
+			}
+
+			if db, err := core.Open(dir, core.Options{EncryptionKey: test.oldKey}); err == nil {
+				db.Close()
+				t.Fatal("Open with the old key succeeded after Rekey")
+			}
+			readProduct(t, dir, newKey)
+		})
+	}
+}
+
+func TestRekeyRejectsWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte("k"), 16)
+	writeProduct(t, dir, key)
+
+	if err := core.Rekey(dir, bytes.Repeat([]byte("x"), 16), key); err == nil {
+		t.Error("Rekey with the wrong old key succeeded")
+	}
+	if err := core.Rekey(dir, key, []byte("short")); err == nil {
+		t.Error("Rekey to a key of invalid length succeeded")
+	}
+	readProduct(t, dir, key)
+}
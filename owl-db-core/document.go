@@ -1,5 +1,13 @@
 package core
 
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 // Document is an interface that requires any type to have an ID field.
 type Document interface {
 	GetID() string
@@ -7,3 +15,68 @@ type Document interface {
 	SetCreatedAt()
 	SetUpdatedAt()
 }
+
+// RawDocument is a Document of any shape, for tools such as import and
+// export that move documents without knowing their type. Fields keep their
+// order.
+type RawDocument bson.D
+
+// GetID returns the _id field, as hex if it is an ObjectID.
+func (d *RawDocument) GetID() string {
+	for _, elem := range *d {
+		if elem.Key == "_id" {
+			return idString(elem.Value)
+		}
+	}
+	return ""
+}
+
+// SetID sets the _id field as a string, adding it first if it is missing.
+func (d *RawDocument) SetID(id string) {
+	d.set("_id", id, true)
+}
+
+func (d *RawDocument) SetCreatedAt() {
+	d.set("createdAt", time.Now(), false)
+}
+
+func (d *RawDocument) SetUpdatedAt() {
+	d.set("updatedAt", time.Now(), false)
+}
+
+// set replaces the value of a field, or adds the field at the start or the
+// end of the document.
+func (d *RawDocument) set(key string, value interface{}, first bool) {
+	for i := range *d {
+		if (*d)[i].Key == key {
+			(*d)[i].Value = value
+			return
+		}
+	}
+	if first {
+		*d = append(RawDocument{{Key: key, Value: value}}, *d...)
+	} else {
+		*d = append(*d, bson.E{Key: key, Value: value})
+	}
+}
+
+// idString returns the string a document is keyed by for an _id of any
+// type: the hex of an ObjectID, or the printed value of a number. The stored
+// document keeps the _id's own type.
+func idString(id interface{}) string {
+	switch id := id.(type) {
+	case nil:
+		return ""
+	case string:
+		return id
+	case primitive.ObjectID:
+		return id.Hex()
+	default:
+		return fmt.Sprint(id)
+	}
+}
+
+// documentID returns the key of a decoded document's _id.
+func documentID(doc map[string]interface{}) string {
+	return idString(doc["_id"])
+}
@@ -0,0 +1,14 @@
+package core_test
+
+// product is the document the tests store.
+type product struct {
+	ID    string  `bson:"_id"`
+	Name  string  `bson:"name"`
+	Price float64 `bson:"price"`
+	Qty   int     `bson:"qty"`
+}
+
+func (p *product) GetID() string   { return p.ID }
+func (p *product) SetID(id string) { p.ID = id }
+func (p *product) SetCreatedAt()   {}
+func (p *product) SetUpdatedAt()   {}
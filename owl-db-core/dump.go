@@ -0,0 +1,249 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Files of a mongodump directory, per collection
+const (
+	dumpDataSuffix     = ".bson"
+	dumpMetadataSuffix = ".metadata.json"
+)
+
+type DumpOptions struct {
+	Collections []string // Dump only these collections, every collection if empty
+}
+
+type LoadDumpOptions struct {
+	Collections []string // Load only these collections, every collection in the dump if empty
+}
+
+// dumpMetadata is the part of a mongodump metadata file owl-db uses
+type dumpMetadata struct {
+	Indexes []dumpIndex `bson:"indexes"`
+}
+
+type dumpIndex struct {
+	Key  bson.D `bson:"key"`
+	Name string `bson:"name"`
+}
+
+// Dump writes collections to dir in the layout mongodump uses for one
+// database: <collection>.bson holding the documents and
+// <collection>.metadata.json holding the index specs, so the directory can be
+// restored into MongoDB with `mongorestore --db <name> <dir>`. Each
+// collection is read in one transaction. Documents keep the type of their
+// _ids: string for the ones owl-db generates, and whatever type a loaded or
+// imported document had.
+func (db *DB) Dump(dir string, opts ...DumpOptions) error {
+	var options DumpOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	names := options.Collections
+	if len(names) == 0 {
+		names = db.CollectionNames()
+	} else if _, _, err := db.selectCollections(names); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := db.dumpCollection(dir, name); err != nil {
+			return fmt.Errorf("failed to dump collection %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) dumpCollection(dir, name string) error {
+	c := NewCollection[*RawDocument](db, name)
+	base := filepath.Join(dir, url.PathEscape(name))
+
+	file, err := os.Create(base + dumpDataSuffix)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := c.Export(file, BSONFormat); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	fields, err := c.indexedFields()
+	if err != nil {
+		return err
+	}
+	metadata, err := bson.MarshalExtJSON(dumpMetadataFor(name, fields), true, false)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(base+dumpMetadataSuffix, metadata, 0644)
+}
+
+// dumpMetadataFor describes a collection in the metadata format of
+// mongodump, with the _id index MongoDB always has and an ascending index per
+// indexed field.
+func dumpMetadataFor(name string, fields []string) bson.D {
+	indexes := bson.A{bson.D{
+		{Key: "v", Value: int32(2)},
+		{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+		{Key: "name", Value: "_id_"},
+	}}
+	for _, field := range fields {
+		indexes = append(indexes, bson.D{
+			{Key: "v", Value: int32(2)},
+			{Key: "key", Value: bson.D{{Key: field, Value: int32(1)}}},
+			{Key: "name", Value: field + "_1"},
+		})
+	}
+
+	return bson.D{
+		{Key: "indexes", Value: indexes},
+		{Key: "collectionName", Value: name},
+		{Key: "type", Value: "collection"},
+	}
+}
+
+// indexedFields returns the fields the collection has index entries for,
+// which include the fields of c.Indexes once a document has them.
+func (c *Collection[T]) indexedFields() ([]string, error) {
+	var fields []string
+
+	err := c.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		prefix := []byte(c.idxPrefix)
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); {
+			rest := iter.Item().Key()[len(prefix):]
+			end := bytes.IndexByte(rest, keySeparator[0])
+			if end < 0 {
+				iter.Next()
+				continue
+			}
+
+			field := string(rest[:end])
+			fields = append(fields, field)
+			// Skip the other entries of the field
+			iter.Seek([]byte(c.idxPrefix + field + string(keySeparator[0]+1)))
+		}
+		return nil
+	})
+	return fields, err
+}
+
+// LoadDump inserts the collections of a mongodump directory, such as one
+// database's directory of a mongodump --out directory, creating the
+// collections that don't exist and indexing the fields of their index specs.
+// Documents with an _id already in the collection replace it. Documents keep
+// the type of their _ids, so a dump of them holds the same ObjectIDs again.
+// System collections are skipped.
+func (db *DB) LoadDump(dir string, opts ...LoadDumpOptions) error {
+	var options LoadDumpOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var selected map[string]bool
+	if len(options.Collections) > 0 {
+		selected = make(map[string]bool, len(options.Collections))
+		for _, name := range options.Collections {
+			selected[name] = true
+		}
+	}
+
+	loaded := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), dumpDataSuffix) {
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), dumpDataSuffix)
+		name, err := url.PathUnescape(base)
+		if err != nil {
+			name = base
+		}
+		if strings.HasPrefix(name, "system.") || (selected != nil && !selected[name]) {
+			continue
+		}
+
+		if err := db.loadCollection(filepath.Join(dir, base), name); err != nil {
+			return fmt.Errorf("failed to load collection %s: %w", name, err)
+		}
+		loaded[name] = true
+	}
+
+	for name := range selected {
+		if !loaded[name] {
+			return fmt.Errorf("collection %s is not in the dump %s", name, dir)
+		}
+	}
+	return nil
+}
+
+func (db *DB) loadCollection(base, name string) error {
+	fields, err := readDumpIndexes(base + dumpMetadataSuffix)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(base + dumpDataSuffix)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	c := NewCollection[*RawDocument](db, name, CollectionOptions{Indexes: fields})
+	_, err = c.Import(file, BSONFormat)
+	return err
+}
+
+// readDumpIndexes returns the fields of the index specs of a metadata file,
+// or none if there is no metadata. owl-db indexes fields one by one, so each
+// field of a compound index is indexed on its own.
+func readDumpIndexes(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata dumpMetadata
+	if err := bson.UnmarshalExtJSON(data, false, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata %s: %w", path, err)
+	}
+
+	var fields []string
+	for _, index := range metadata.Indexes {
+		for _, key := range index.Key {
+			if key.Key != "_id" {
+				fields = append(fields, key.Key)
+			}
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,47 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDumpLoadKeepsObjectIDs(t *testing.T) {
+	source := testutil.OpenDB(t)
+	id := primitive.NewObjectID()
+	doc := core.RawDocument{{Key: "_id", Value: id}, {Key: "name", Value: "lamp"}}
+	if err := core.NewCollection[*core.RawDocument](source, "products").Insert(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := source.Dump(dir); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	loaded := testutil.OpenDB(t)
+	if err := loaded.LoadDump(dir); err != nil {
+		t.Fatalf("LoadDump: %v", err)
+	}
+
+	// The loaded document dumps with its ObjectID, as mongorestore reads it
+	again := t.TempDir()
+	if err := loaded.Dump(again); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(again, "products.bson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dumped bson.M
+	if err := bson.Unmarshal(data, &dumped); err != nil {
+		t.Fatal(err)
+	}
+	if dumped["_id"] != id {
+		t.Errorf("dumped _id = %#v, want ObjectID %s", dumped["_id"], id.Hex())
+	}
+}
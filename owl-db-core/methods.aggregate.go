@@ -0,0 +1,486 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// aggregateStage transforms the documents flowing through a pipeline.
+type aggregateStage func(docs []map[string]interface{}) ([]map[string]interface{}, error)
+
+// Aggregate runs pipeline over the documents of the collection and returns
+// the documents that come out of it, e.g.
+//
+//	totals, err := orders.Aggregate(core.Pipeline{
+//		{"$match": map[string]interface{}{"status": "paid"}},
+//		{"$group": map[string]interface{}{
+//			"_id":   "$customerName",
+//			"spent": map[string]interface{}{"$sum": "$totalPrice"},
+//		}},
+//		{"$sort": map[string]interface{}{"spent": -1}},
+//	})
+//
+// Besides the stages of update pipelines it supports $match, $group (with
+// $sum, $avg, $min, $max, $first, $last, $push, $addToSet and $count),
+// $unwind, $sort, $skip, $limit and $count. A $sort on several fields needs
+// an ordered spec, a bson.D or []SortField, since maps have no order. A
+// leading $match filters the documents as they are read; the rest of the
+// pipeline runs in memory.
+func (c *Collection[T]) Aggregate(pipeline Pipeline) ([]map[string]interface{}, error) {
+	var filter Filter
+	if len(pipeline) > 0 {
+		if spec, ok := pipeline[0]["$match"]; ok && len(pipeline[0]) == 1 {
+			if filter, ok = toFilter(spec); !ok {
+				return nil, fmt.Errorf("pipeline stage 0: $match takes a filter")
+			}
+			pipeline = pipeline[1:]
+		}
+	}
+
+	stages := make([]aggregateStage, 0, len(pipeline))
+	for i, stage := range pipeline {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("pipeline stage %d must have exactly one field", i)
+		}
+		for name, spec := range stage {
+			compiled, err := compileAggregateStage(name, spec)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline stage %d: %v", i, err)
+			}
+			stages = append(stages, compiled)
+		}
+	}
+
+	var docs []map[string]interface{}
+	err := c.view(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		prefix := []byte(c.nodePrefix)
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			var doc map[string]interface{}
+			err := iter.Item().Value(func(val []byte) error {
+				return bson.Unmarshal(val, &doc)
+			})
+			if err != nil {
+				return err
+			}
+			if filter == nil || matchDocument(doc, filter) {
+				docs = append(docs, doc)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stage := range stages {
+		if docs, err = stage(docs); err != nil {
+			return nil, err
+		}
+	}
+	if docs == nil {
+		docs = []map[string]interface{}{}
+	}
+	return docs, nil
+}
+
+func compileAggregateStage(name string, spec interface{}) (aggregateStage, error) {
+	switch name {
+	case "$match":
+		filter, ok := toFilter(spec)
+		if !ok {
+			return nil, fmt.Errorf("$match takes a filter")
+		}
+		return func(docs []map[string]interface{}) ([]map[string]interface{}, error) {
+			matched := docs[:0]
+			for _, doc := range docs {
+				if matchDocument(doc, filter) {
+					matched = append(matched, doc)
+				}
+			}
+			return matched, nil
+		}, nil
+
+	case "$set", "$addFields", "$unset", "$project":
+		if err := validateStage(name, spec); err != nil {
+			return nil, err
+		}
+		return func(docs []map[string]interface{}) ([]map[string]interface{}, error) {
+			for _, doc := range docs {
+				if err := applyDocumentStage(doc, name, spec); err != nil {
+					return nil, err
+				}
+			}
+			return docs, nil
+		}, nil
+
+	case "$group":
+		return compileGroupStage(spec)
+
+	case "$unwind":
+		return compileUnwindStage(spec)
+
+	case "$sort":
+		sortFields, err := aggregateSortFields(spec)
+		if err != nil {
+			return nil, err
+		}
+		return func(docs []map[string]interface{}) ([]map[string]interface{}, error) {
+			sort.SliceStable(docs, func(i, j int) bool {
+				return compareBySortFields(docs[i], docs[j], sortFields) < 0
+			})
+			return docs, nil
+		}, nil
+
+	case "$skip", "$limit":
+		n, _, _, ok := toNumber(spec)
+		if !ok || n < 0 || (name == "$limit" && n == 0) {
+			return nil, fmt.Errorf("%s takes a positive number", name)
+		}
+		return func(docs []map[string]interface{}) ([]map[string]interface{}, error) {
+			if name == "$skip" {
+				return docs[min(int(n), len(docs)):], nil
+			}
+			return docs[:min(int(n), len(docs))], nil
+		}, nil
+
+	case "$count":
+		field, ok := spec.(string)
+		if !ok || field == "" || strings.HasPrefix(field, "$") || strings.Contains(field, ".") {
+			return nil, fmt.Errorf("$count takes a field name")
+		}
+		return func(docs []map[string]interface{}) ([]map[string]interface{}, error) {
+			if len(docs) == 0 {
+				return nil, nil
+			}
+			return []map[string]interface{}{{field: narrowInt(int64(len(docs)))}}, nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported aggregation stage: %s", name)
+}
+
+// accumulator folds the values of one field of a group.
+type accumulator struct {
+	field string
+	op    string
+	expr  interface{}
+}
+
+func compileGroupStage(spec interface{}) (aggregateStage, error) {
+	fields, ok := toFilter(spec)
+	if !ok {
+		return nil, fmt.Errorf("$group takes a document")
+	}
+	idExpr, ok := fields["_id"]
+	if !ok {
+		return nil, fmt.Errorf("$group needs an _id, null to group every document")
+	}
+
+	var accumulators []accumulator
+	for field, rawAccumulator := range fields {
+		if field == "_id" {
+			continue
+		}
+		operator, ok := toFilter(rawAccumulator)
+		if !ok || len(operator) != 1 {
+			return nil, fmt.Errorf("$group field %s must be an accumulator like {\"$sum\": \"$price\"}", field)
+		}
+		for op, expr := range operator {
+			switch op {
+			case "$sum", "$avg", "$min", "$max", "$first", "$last", "$push", "$addToSet", "$count":
+			default:
+				return nil, fmt.Errorf("unsupported accumulator %s for $group field %s", op, field)
+			}
+			accumulators = append(accumulators, accumulator{field: field, op: op, expr: expr})
+		}
+	}
+
+	return func(docs []map[string]interface{}) ([]map[string]interface{}, error) {
+		var groups []map[string]interface{}
+		var members [][]map[string]interface{}
+		index := make(map[string]int)
+
+		for _, doc := range docs {
+			id, err := evaluateExpression(idExpr, doc)
+			if err != nil {
+				return nil, fmt.Errorf("cannot compute $group _id: %v", err)
+			}
+			key := groupKey(id)
+			i, ok := index[key]
+			if !ok {
+				i = len(groups)
+				index[key] = i
+				groups = append(groups, map[string]interface{}{"_id": id})
+				members = append(members, nil)
+			}
+			members[i] = append(members[i], doc)
+		}
+
+		for i, group := range groups {
+			for _, acc := range accumulators {
+				value, err := acc.apply(members[i])
+				if err != nil {
+					return nil, fmt.Errorf("cannot compute %s: %v", acc.field, err)
+				}
+				group[acc.field] = value
+			}
+		}
+		return groups, nil
+	}, nil
+}
+
+// apply computes the accumulator over the documents of one group. Like
+// MongoDB, $sum and $avg skip values that are not numbers and $min and $max
+// skip missing and null values.
+func (acc accumulator) apply(docs []map[string]interface{}) (interface{}, error) {
+	if acc.op == "$count" {
+		return narrowInt(int64(len(docs))), nil
+	}
+
+	values := make([]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		value, err := evaluateExpression(acc.expr, doc)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	switch acc.op {
+	case "$sum", "$avg":
+		var sum interface{} = int32(0)
+		var count int
+		for _, value := range values {
+			if _, _, _, ok := toNumber(value); !ok {
+				continue
+			}
+			var err error
+			if sum, err = addNumbers(sum, value); err != nil {
+				return nil, err
+			}
+			count++
+		}
+		if acc.op == "$sum" {
+			return sum, nil
+		}
+		if count == 0 {
+			return nil, nil
+		}
+		_, total, _, _ := toNumber(sum)
+		return total / float64(count), nil
+
+	case "$min", "$max":
+		var best interface{}
+		for _, value := range values {
+			if value == nil {
+				continue
+			}
+			if best == nil {
+				best = value
+				continue
+			}
+			result, ok := compareValues(value, best)
+			if ok && ((acc.op == "$min" && result < 0) || (acc.op == "$max" && result > 0)) {
+				best = value
+			}
+		}
+		return best, nil
+
+	case "$first":
+		return values[0], nil
+
+	case "$last":
+		return values[len(values)-1], nil
+
+	case "$push":
+		return values, nil
+
+	case "$addToSet":
+		set := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			if !containsValue(set, value) {
+				set = append(set, value)
+			}
+		}
+		return set, nil
+	}
+	return nil, nil
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, existing := range values {
+		if valuesEqual(existing, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupKey identifies a $group _id. Numbers of different types that are
+// equal, like int32(1) and 1.0, land in the same group.
+func groupKey(id interface{}) string {
+	return fmt.Sprintf("%#v", normalizeGroupKey(id))
+}
+
+func normalizeGroupKey(value interface{}) interface{} {
+	if _, f, _, ok := toNumber(value); ok {
+		return f
+	}
+	if t, ok := toTime(value); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	if doc, ok := value.(map[string]interface{}); ok {
+		normalized := make(map[string]interface{}, len(doc))
+		for key, field := range doc {
+			normalized[key] = normalizeGroupKey(field)
+		}
+		return normalized
+	}
+	if array, ok := toArray(value); ok {
+		normalized := make([]interface{}, len(array))
+		for i, element := range array {
+			normalized[i] = normalizeGroupKey(element)
+		}
+		return normalized
+	}
+	return value
+}
+
+// compileUnwindStage handles "$field" and
+// {"path": "$field", "preserveNullAndEmptyArrays": true}.
+func compileUnwindStage(spec interface{}) (aggregateStage, error) {
+	path, preserve := "", false
+	switch s := spec.(type) {
+	case string:
+		path = s
+	default:
+		options, ok := toFilter(spec)
+		if !ok {
+			return nil, fmt.Errorf("$unwind takes a field path or a document")
+		}
+		path, _ = options["path"].(string)
+		preserve, _ = options["preserveNullAndEmptyArrays"].(bool)
+	}
+	if !strings.HasPrefix(path, "$") || len(path) == 1 {
+		return nil, fmt.Errorf("$unwind path must be a field path starting with $")
+	}
+	path = path[1:]
+	keys := strings.Split(path, ".")
+
+	return func(docs []map[string]interface{}) ([]map[string]interface{}, error) {
+		var unwound []map[string]interface{}
+		for _, doc := range docs {
+			value, exists := getNestedValue(doc, keys)
+			array, isArray := toArray(value)
+			if !isArray {
+				// A single value unwinds to itself
+				if exists && value != nil {
+					unwound = append(unwound, doc)
+				} else if preserve {
+					unwound = append(unwound, doc)
+				}
+				continue
+			}
+			if len(array) == 0 {
+				if preserve {
+					copied := copyDocument(doc)
+					deleteNestedField(copied, path)
+					unwound = append(unwound, copied)
+				}
+				continue
+			}
+
+			for _, element := range array {
+				copied := copyDocument(doc)
+				if err := updateNestedField(copied, path, element); err != nil {
+					return nil, err
+				}
+				unwound = append(unwound, copied)
+			}
+		}
+		return unwound, nil
+	}, nil
+}
+
+// copyDocument copies doc deeply, so that changing one of its fields, nested
+// or not, leaves doc untouched.
+func copyDocument(doc map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(doc))
+	for key, value := range doc {
+		copied[key] = copyValue(value)
+	}
+	return copied
+}
+
+// aggregateSortFields reads a $sort spec: a bson.D or []SortField for any
+// number of fields, or a map for one field.
+func aggregateSortFields(spec interface{}) ([]SortField, error) {
+	var sortFields []SortField
+	switch s := spec.(type) {
+	case []SortField:
+		sortFields = s
+	case bson.D:
+		for _, elem := range s {
+			sortFields = append(sortFields, SortField{Field: elem.Key, Order: sortOrder(elem.Value)})
+		}
+	default:
+		fields, ok := toFilter(spec)
+		if !ok {
+			return nil, fmt.Errorf("$sort takes a document of field -> 1 or -1")
+		}
+		if len(fields) > 1 {
+			return nil, fmt.Errorf("$sort on several fields needs a bson.D or []SortField to keep their order")
+		}
+		for field, order := range fields {
+			sortFields = append(sortFields, SortField{Field: field, Order: sortOrder(order)})
+		}
+	}
+
+	if len(sortFields) == 0 {
+		return nil, fmt.Errorf("$sort needs at least one field")
+	}
+	for _, sortField := range sortFields {
+		if sortField.Order != 1 && sortField.Order != -1 {
+			return nil, fmt.Errorf("$sort order of %s must be 1 or -1", sortField.Field)
+		}
+	}
+	return sortFields, nil
+}
+
+func sortOrder(value interface{}) int {
+	order, _ := toDirection(value)
+	return order
+}
+
+// compareBySortFields orders two documents by the sort fields. Missing and
+// null values sort before any other value, as in MongoDB.
+func compareBySortFields(a, b map[string]interface{}, sortFields []SortField) int {
+	for _, sortField := range sortFields {
+		keys := strings.Split(sortField.Field, ".")
+		valueA, _ := getNestedValue(a, keys)
+		valueB, _ := getNestedValue(b, keys)
+
+		var result int
+		switch {
+		case valueA == nil && valueB == nil:
+		case valueA == nil:
+			result = -1
+		case valueB == nil:
+			result = 1
+		default:
+			result, _ = compareValues(valueA, valueB)
+		}
+		if result != 0 {
+			return result * sortField.Order
+		}
+	}
+	return 0
+}
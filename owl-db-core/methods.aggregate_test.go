@@ -0,0 +1,147 @@
+package core_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAggregate(t *testing.T) {
+	orders := core.NewCollection[*core.RawDocument](testutil.OpenDB(t), "orders")
+	testutil.Seed(t, orders, "testdata/orders.json")
+
+	for _, test := range []struct {
+		name     string
+		pipeline core.Pipeline
+		want     string
+	}{
+		{
+			name: "match group sort",
+			pipeline: core.Pipeline{
+				{"$match": map[string]interface{}{"status": "paid"}},
+				{"$group": map[string]interface{}{"_id": "$customer", "spent": map[string]interface{}{"$sum": "$total"}}},
+				{"$sort": map[string]interface{}{"spent": -1}},
+			},
+			want: "[map[_id:ann spent:40] map[_id:bob spent:20]]",
+		},
+		{
+			name: "group everything",
+			pipeline: core.Pipeline{
+				{"$group": map[string]interface{}{
+					"_id": nil,
+					"avg": map[string]interface{}{"$avg": "$total"},
+					"n":   map[string]interface{}{"$count": map[string]interface{}{}},
+				}},
+			},
+			want: "[map[_id:<nil> avg:16.25 n:4]]",
+		},
+		{
+			name: "accumulators",
+			pipeline: core.Pipeline{
+				{"$match": map[string]interface{}{"customer": "ann"}},
+				{"$group": map[string]interface{}{
+					"_id":      "$customer",
+					"first":    map[string]interface{}{"$first": "$_id"},
+					"last":     map[string]interface{}{"$last": "$_id"},
+					"min":      map[string]interface{}{"$min": "$total"},
+					"max":      map[string]interface{}{"$max": "$total"},
+					"ids":      map[string]interface{}{"$push": "$_id"},
+					"statuses": map[string]interface{}{"$addToSet": "$status"},
+				}},
+			},
+			want: "[map[_id:ann first:o1 ids:[o1 o3 o4] last:o4 max:30 min:5 statuses:[paid open]]]",
+		},
+		{
+			name: "unwind",
+			pipeline: core.Pipeline{
+				{"$unwind": "$items"},
+				{"$project": map[string]interface{}{"items": 1}},
+			},
+			want: "[map[_id:o1 items:lamp] map[_id:o1 items:desk] map[_id:o2 items:chair]]",
+		},
+		{
+			name: "unwind preserving empty arrays",
+			pipeline: core.Pipeline{
+				{"$unwind": map[string]interface{}{"path": "$items", "preserveNullAndEmptyArrays": true}},
+				{"$project": map[string]interface{}{"items": 1}},
+			},
+			want: "[map[_id:o1 items:lamp] map[_id:o1 items:desk] map[_id:o2 items:chair] map[_id:o3] map[_id:o4]]",
+		},
+		{
+			name: "sort on several fields",
+			pipeline: core.Pipeline{
+				{"$sort": bson.D{{Key: "customer", Value: -1}, {Key: "total", Value: 1}}},
+				{"$project": map[string]interface{}{"total": 1}},
+			},
+			want: "[map[_id:o2 total:20] map[_id:o3 total:5] map[_id:o4 total:10] map[_id:o1 total:30]]",
+		},
+		{
+			name: "skip and limit",
+			pipeline: core.Pipeline{
+				{"$sort": map[string]interface{}{"total": 1}},
+				{"$skip": 1},
+				{"$limit": 2},
+				{"$project": map[string]interface{}{"total": 1}},
+			},
+			want: "[map[_id:o4 total:10] map[_id:o2 total:20]]",
+		},
+		{
+			name: "count",
+			pipeline: core.Pipeline{
+				{"$match": map[string]interface{}{"status": "paid"}},
+				{"$count": "paid"},
+			},
+			want: "[map[paid:3]]",
+		},
+		{
+			name: "set and unset",
+			pipeline: core.Pipeline{
+				{"$match": map[string]interface{}{"_id": "o2"}},
+				{"$set": map[string]interface{}{"double": map[string]interface{}{"$multiply": []interface{}{"$total", 2}}}},
+				{"$unset": []interface{}{"items", "customer", "status"}},
+			},
+			want: "[map[_id:o2 double:40 total:20]]",
+		},
+		{
+			name: "no documents",
+			pipeline: core.Pipeline{
+				{"$match": map[string]interface{}{"status": "refunded"}},
+			},
+			want: "[]",
+		},
+	} {
+		docs, err := orders.Aggregate(test.pipeline)
+		if err != nil {
+			t.Fatalf("%s: Aggregate: %v", test.name, err)
+		}
+		if got := fmt.Sprint(docs); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAggregateRejectsInvalidStages(t *testing.T) {
+	orders := core.NewCollection[*core.RawDocument](testutil.OpenDB(t), "orders")
+
+	for _, test := range []struct {
+		pipeline core.Pipeline
+		wantErr  string
+	}{
+		{core.Pipeline{{"$lookup": map[string]interface{}{}}}, "unsupported aggregation stage"},
+		{core.Pipeline{{"$skip": 1, "$limit": 1}}, "exactly one field"},
+		{core.Pipeline{{"$group": map[string]interface{}{"n": map[string]interface{}{"$sum": 1}}}}, "needs an _id"},
+		{core.Pipeline{{"$group": map[string]interface{}{"_id": nil, "n": map[string]interface{}{"$median": 1}}}}, "unsupported accumulator"},
+		{core.Pipeline{{"$sort": map[string]interface{}{"customer": 1, "total": -1}}}, "needs a bson.D"},
+		{core.Pipeline{{"$unwind": "items"}}, "must be a field path"},
+		{core.Pipeline{{"$limit": -1}}, "positive number"},
+	} {
+		_, err := orders.Aggregate(test.pipeline)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("Aggregate(%v) error = %v, want %q", test.pipeline, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// WriteModel is one operation of a BulkWrite: InsertOneModel,
+// UpdateOneModel, UpdateManyModel, ReplaceOneModel, DeleteOneModel or
+// DeleteManyModel.
+type WriteModel interface {
+	writeModel()
+}
+
+type InsertOneModel struct {
+	Document Document // Must be of the collection's document type
+}
+
+type UpdateOneModel struct {
+	Filter       Filter
+	Update       UpdateSpec
+	Upsert       bool
+	ArrayFilters []Filter
+}
+
+type UpdateManyModel struct {
+	Filter       Filter
+	Update       UpdateSpec
+	Upsert       bool
+	ArrayFilters []Filter
+}
+
+type ReplaceOneModel struct {
+	Filter      Filter
+	Replacement Document // Must be of the collection's document type
+	Upsert      bool
+}
+
+type DeleteOneModel struct {
+	Filter Filter
+}
+
+type DeleteManyModel struct {
+	Filter Filter
+}
+
+func (InsertOneModel) writeModel()  {}
+func (UpdateOneModel) writeModel()  {}
+func (UpdateManyModel) writeModel() {}
+func (ReplaceOneModel) writeModel() {}
+func (DeleteOneModel) writeModel()  {}
+func (DeleteManyModel) writeModel() {}
+
+type BulkWriteOptions struct {
+	Ordered bool // Stop at the first failing operation instead of running the rest
+}
+
+type BulkWriteResult struct {
+	InsertedCount int
+	MatchedCount  int
+	UpsertedCount int
+	DeletedCount  int
+	UpsertedIDs   map[int]string // Upserted document IDs by operation index
+	Errors        BulkWriteErrors
+}
+
+// BulkWriteError is the failure of the operation at Index.
+type BulkWriteError struct {
+	Index int
+	Err   error
+}
+
+func (e BulkWriteError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e BulkWriteError) Unwrap() error {
+	return e.Err
+}
+
+// BulkWriteErrors is returned by BulkWrite when any operation failed.
+type BulkWriteErrors []BulkWriteError
+
+func (e BulkWriteErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("bulk write failed: %s", strings.Join(messages, "; "))
+}
+
+// Unwrap lets errors.Is and errors.As see the errors of the operations.
+func (e BulkWriteErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Internal signals used to discard a transaction and run its batch again
+var (
+	errBatchFull = errors.New("bulk write batch is full")
+	errOpFailed  = errors.New("bulk write operation failed")
+)
+
+// BulkWrite runs a mix of write operations, packing as many consecutive
+// operations as possible into each transaction. An operation that fails is
+// rolled back on its own: its batch is run again without it.
+//
+// In ordered mode the operations before the first failure are committed and
+// the rest are skipped. In unordered mode every other operation still runs and
+// each failure is reported with its index. Inside a transaction (WithTx) the
+// first failure is returned, and the caller should abort the transaction.
+func (c *Collection[T]) BulkWrite(models []WriteModel, bulkOptions ...BulkWriteOptions) (BulkWriteResult, error) {
+	var options BulkWriteOptions
+	if len(bulkOptions) > 0 {
+		options = bulkOptions[0]
+	}
+
+	// Compile every update up front so malformed operations fail before anything is written
+	compiled := make([]compiledUpdate, len(models))
+	failed := make(map[int]error)
+	for i, model := range models {
+		var err error
+		compiled[i], err = c.compileWriteModel(model)
+		if err != nil {
+			failed[i] = err
+			if options.Ordered {
+				break
+			}
+		}
+	}
+
+	result := BulkWriteResult{UpsertedIDs: make(map[int]string)}
+
+	if c.tx != nil {
+		for i, model := range models {
+			if err, ok := failed[i]; ok {
+				return result, BulkWriteErrors{{Index: i, Err: err}}
+			}
+			var applyErr error
+			err := c.tx.run(c.Db, func(txn *badger.Txn) error {
+				applyErr = c.applyWriteModel(txn, i, model, compiled[i], &result)
+				return applyErr
+			})
+			if applyErr != nil {
+				return result, BulkWriteErrors{{Index: i, Err: applyErr}}
+			}
+			if err != nil {
+				return result, err // The transaction itself can't be used
+			}
+		}
+		return result, nil
+	}
+
+	start := 0
+	for start < len(models) {
+		if _, ok := failed[start]; ok && options.Ordered {
+			break
+		}
+
+		limit := len(models)
+		for i := start; i < limit; i++ {
+			if _, ok := failed[i]; ok && options.Ordered {
+				limit = i
+			}
+		}
+
+		var batch BulkWriteResult
+		for {
+			err := c.update(func(txn *badger.Txn) error {
+				batch = BulkWriteResult{UpsertedIDs: make(map[int]string)}
+				for i := start; i < limit; i++ {
+					if _, ok := failed[i]; ok {
+						continue
+					}
+
+					err := c.applyWriteModel(txn, i, models[i], compiled[i], &batch)
+					if err == nil {
+						continue
+					}
+					if errors.Is(err, badger.ErrTxnTooBig) && i > start {
+						// Commit the operations that fit and start a new transaction at i
+						limit = i
+						return errBatchFull
+					}
+
+					failed[i] = err
+					if options.Ordered {
+						limit = i
+					}
+					return errOpFailed
+				}
+				return nil
+			})
+			if errors.Is(err, errBatchFull) || errors.Is(err, errOpFailed) {
+				continue // Run the batch again without the failed or overflowing operation
+			}
+			if err != nil {
+				for i := start; i < limit; i++ {
+					if _, ok := failed[i]; !ok {
+						failed[i] = err
+					}
+				}
+			}
+			break
+		}
+
+		result.add(batch)
+		start = limit
+	}
+
+	for i := range models {
+		if err, ok := failed[i]; ok {
+			result.Errors = append(result.Errors, BulkWriteError{Index: i, Err: err})
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result, result.Errors
+	}
+	return result, nil
+}
+
+// compileWriteModel checks a model and compiles its update or replacement.
+func (c *Collection[T]) compileWriteModel(model WriteModel) (compiledUpdate, error) {
+	switch m := model.(type) {
+	case InsertOneModel:
+		if _, ok := m.Document.(T); !ok {
+			return nil, fmt.Errorf("document of type %T does not belong to collection %s", m.Document, c.Name)
+		}
+		return nil, nil
+	case UpdateOneModel:
+		return compileUpdateSpec(m.Update)
+	case UpdateManyModel:
+		return compileUpdateSpec(m.Update)
+	case ReplaceOneModel:
+		doc, ok := m.Replacement.(T)
+		if !ok {
+			return nil, fmt.Errorf("document of type %T does not belong to collection %s", m.Replacement, c.Name)
+		}
+		return c.replacementFor(doc)
+	case DeleteOneModel, DeleteManyModel:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported write model %T", model)
+}
+
+// applyWriteModel runs one operation in txn and adds its counts to result.
+func (c *Collection[T]) applyWriteModel(txn *badger.Txn, index int, model WriteModel, update compiledUpdate, result *BulkWriteResult) error {
+	var updated UpdateResult
+	var err error
+
+	switch m := model.(type) {
+	case InsertOneModel:
+		if err := nativeInsert(c, txn, m.Document.(T)); err != nil {
+			return err
+		}
+		result.InsertedCount++
+		return nil
+
+	case UpdateOneModel:
+		options := UpdateOptions{Upsert: m.Upsert, ArrayFilters: m.ArrayFilters}
+		updated, err = nativeUpdateOne(c, txn, m.Filter, update, options)
+
+	case UpdateManyModel:
+		options := UpdateOptions{Upsert: m.Upsert, ArrayFilters: m.ArrayFilters}
+		updated, err = nativeUpdateMany(c, txn, m.Filter, update, options)
+
+	case ReplaceOneModel:
+		updated, err = nativeUpdateOne(c, txn, m.Filter, update, UpdateOptions{Upsert: m.Upsert})
+
+	case DeleteOneModel:
+		deleted, err := nativeDeleteOne(c, txn, m.Filter)
+		result.DeletedCount += deleted
+		return err
+
+	case DeleteManyModel:
+		deleted, err := nativeDeleteMany(c, txn, m.Filter)
+		result.DeletedCount += deleted
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+	result.MatchedCount += updated.MatchedCount
+	if updated.UpsertedID != "" {
+		result.UpsertedCount++
+		result.UpsertedIDs[index] = updated.UpsertedID
+	}
+	return nil
+}
+
+func (r *BulkWriteResult) add(other BulkWriteResult) {
+	r.InsertedCount += other.InsertedCount
+	r.MatchedCount += other.MatchedCount
+	r.UpsertedCount += other.UpsertedCount
+	r.DeletedCount += other.DeletedCount
+	for index, id := range other.UpsertedIDs {
+		r.UpsertedIDs[index] = id
+	}
+}
@@ -0,0 +1,34 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+func TestBulkWriteOnFinishedTx(t *testing.T) {
+	db := testutil.OpenDB(t)
+	products := core.NewCollection[*product](db, "products")
+
+	var finished *core.Tx
+	err := db.WithTransaction(context.Background(), func(tx *core.Tx) error {
+		finished = tx
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	models := []core.WriteModel{core.InsertOneModel{Document: &product{ID: "p1", Name: "lamp"}}}
+	_, err = products.WithTx(finished).BulkWrite(models)
+	if !errors.Is(err, core.ErrTxDone) {
+		t.Fatalf("BulkWrite error = %v, want ErrTxDone", err)
+	}
+
+	if _, err := products.FindByID("p1"); err == nil {
+		t.Error("document written through a finished transaction")
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"errors"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Atomicity is the contract of a bulk operation that may touch more documents
+// than fit in one Badger transaction.
+type Atomicity int
+
+const (
+	// AllOrNothing runs the whole operation in one transaction. Very large
+	// result sets fail with badger.ErrTxnTooBig and nothing is written.
+	AllOrNothing Atomicity = iota
+	// Chunked commits the work in bounded transactions one after another.
+	// On error, the chunks committed so far stay written and are reported.
+	Chunked
+)
+
+// defaultChunkSize is the number of documents per transaction in Chunked mode
+const defaultChunkSize = 1000
+
+// Progress is reported after each committed chunk.
+type Progress struct {
+	Processed int // Documents handled by the committed chunks so far
+	Total     int // Documents matched when the operation started
+}
+
+// matchingIDs returns the IDs of every document matching the filter.
+func (c *Collection[T]) matchingIDs(filter Filter) ([]string, error) {
+	var ids []string
+	err := c.view(func(txn *badger.Txn) error {
+		ids = nil
+		for _, doc := range nativeFind(c, txn, filter) {
+			ids = append(ids, documentID(doc))
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// forEachChunk runs fn over ids in separate transactions of at most chunkSize
+// documents. A chunk that exceeds Badger's transaction limits is split in
+// half and retried. fn returns the number of documents it affected; the
+// total over all committed chunks is returned, also on error.
+func (c *Collection[T]) forEachChunk(
+	ids []string,
+	chunkSize int,
+	progress func(Progress),
+	fn func(txn *badger.Txn, ids []string) (int, error),
+) (int, error) {
+	return forEachItemChunk(c, ids, chunkSize, progress, fn)
+}
+
+// forEachItemChunk is forEachChunk over any kind of item, such as the
+// positions of documents to insert.
+func forEachItemChunk[T Document, E any](
+	c *Collection[T],
+	items []E,
+	chunkSize int,
+	progress func(Progress),
+	fn func(txn *badger.Txn, items []E) (int, error),
+) (int, error) {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	var affected, processed int
+	for len(items) > 0 {
+		chunk := items[:min(chunkSize, len(items))]
+
+		var chunkAffected int
+		err := c.update(func(txn *badger.Txn) error {
+			var err error
+			chunkAffected, err = fn(txn, chunk)
+			return err
+		})
+		if errors.Is(err, badger.ErrTxnTooBig) && len(chunk) > 1 {
+			chunkSize = len(chunk) / 2
+			continue
+		}
+		if err != nil {
+			return affected, err
+		}
+
+		affected += chunkAffected
+		processed += len(chunk)
+		items = items[len(chunk):]
+		if progress != nil {
+			progress(Progress{Processed: processed, Total: processed + len(items)})
+		}
+	}
+	return affected, nil
+}
+
+// nativeGet reads and decodes a document by ID. found is false if it does not exist.
+func nativeGet[T Document](c *Collection[T], txn *badger.Txn, docID string) (map[string]interface{}, bool, error) {
+	key := c.docKey(docID)
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var doc map[string]interface{}
+	err = item.Value(func(val []byte) error {
+		return bson.Unmarshal(val, &doc)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return doc, true, nil
+}
@@ -0,0 +1,46 @@
+package core_test
+
+import (
+	"fmt"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+func TestDeleteManyChunkedProgress(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products")
+	docs := make([]*product, 25)
+	for i := range docs {
+		docs[i] = &product{ID: fmt.Sprintf("p%02d", i), Qty: i % 2}
+	}
+	if _, err := products.InsertMany(docs); err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []core.Progress
+	deleted, err := products.DeleteMany(core.Filter{"qty": 1}, core.DeleteOptions{
+		Atomicity: core.Chunked,
+		ChunkSize: 5,
+		Progress:  func(progress core.Progress) { reports = append(reports, progress) },
+	})
+	if err != nil {
+		t.Fatalf("DeleteMany: %v", err)
+	}
+	if deleted != 12 {
+		t.Errorf("deleted %d, want 12", deleted)
+	}
+
+	want := []core.Progress{{Processed: 5, Total: 12}, {Processed: 10, Total: 12}, {Processed: 12, Total: 12}}
+	if fmt.Sprint(reports) != fmt.Sprint(want) {
+		t.Errorf("progress %v, want %v", reports, want)
+	}
+
+	left, err := products.Find(core.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 13 {
+		t.Errorf("%d documents left, want 13", len(left))
+	}
+}
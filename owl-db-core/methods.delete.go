@@ -3,110 +3,93 @@ package core
 import (
 	"errors"
 	"fmt"
-	"sync"
 
 	badger "github.com/dgraph-io/badger/v4"
 )
 
 func (c *Collection[T]) DeleteByID(docID string) error {
-	return c.Db.Update(func(txn *badger.Txn) error {
-		key := fmt.Sprintf("%s|%s", c.Name, docID)
+	return c.update(func(txn *badger.Txn) error {
+		key := c.docKey(docID)
 
-		_, err := txn.Get([]byte(key))
+		_, err := txn.Get(key)
 		if err != nil {
 			return err // Document not found
 		}
 
 		// Delete document from the collection
-		err = txn.Delete([]byte(key))
-		if err != nil {
-			return err
-		}
-
-		// Optionally handle index removal if needed
-		// (If you manage indexes similarly as with updates)
-
-		return nil
+		return nativeDelete(c, txn, docID)
 	})
 }
 
 func (c *Collection[T]) DeleteOne(filter Filter) error {
-	return c.Db.Update(func(txn *badger.Txn) error {
-		result := nativeFindOne(c, txn, filter)
-		if !result.found {
-			return errors.New("no document found in result")
-		}
-
-		docID := result.doc["_id"].(string)
-		key := fmt.Sprintf("%s|%s", c.Name, docID)
-
-		// Delete the document from the collection
-		err := txn.Delete([]byte(key))
+	return c.update(func(txn *badger.Txn) error {
+		deleted, err := nativeDeleteOne(c, txn, filter)
 		if err != nil {
 			return err
 		}
-
-		// Optionally handle index removal if needed
-		// (If you manage indexes similarly as with updates)
-
+		if deleted == 0 {
+			return errors.New("no document found in result")
+		}
 		return nil
 	})
 }
 
-func (c *Collection[T]) DeleteMany(filter Filter) error {
-	return c.Db.Update(func(txn *badger.Txn) error {
-		results := nativeFind(c, txn, filter)
+type DeleteOptions struct {
+	Atomicity Atomicity      // AllOrNothing (default) or Chunked
+	ChunkSize int            // Documents per transaction when Chunked, 1000 if zero
+	Progress  func(Progress) // Called after each committed chunk
+}
 
-		if len(results) == 0 {
-			return nil // No matching documents
+// DeleteMany deletes every document matching the filter and returns how many
+// were deleted. By default this happens in a single transaction; with
+// Atomicity set to Chunked the documents are deleted in bounded transactions
+// and Progress is reported after each one, so a failure leaves the earlier
+// chunks deleted.
+func (c *Collection[T]) DeleteMany(filter Filter, deleteOptions ...DeleteOptions) (int, error) {
+	var options DeleteOptions
+	if len(deleteOptions) > 0 {
+		options = deleteOptions[0]
+	}
+
+	if options.Atomicity == Chunked && c.tx == nil {
+		ids, err := c.matchingIDs(filter)
+		if err != nil {
+			return 0, err
 		}
 
-		// Create channels to handle concurrency
-		docCh := make(chan map[string]interface{}, len(results)) // Channel to send docs to worker goroutines
-		errCh := make(chan error, len(results))                  // Channel to capture errors
-		workerCount := 5                                         // Number of concurrent workers
-		var wg sync.WaitGroup                                    // WaitGroup for tracking completion
-
-		// Start worker goroutines
-		for i := 0; i < workerCount; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				for doc := range docCh {
-					docID := doc["_id"].(string)
-					key := fmt.Sprintf("%s|%s", c.Name, docID)
-
-					// Use the passed-in transaction `txn` to delete the document
-					err := txn.Delete([]byte(key))
-					if err != nil {
-						errCh <- fmt.Errorf("failed to delete doc %s: %v", docID, err)
-					}
+		return c.forEachChunk(ids, options.ChunkSize, options.Progress, func(txn *badger.Txn, ids []string) (int, error) {
+			var deleted int
+			for _, docID := range ids {
+				// The document may have changed since the IDs were collected
+				doc, found, err := nativeGet(c, txn, docID)
+				if err != nil {
+					return 0, err
+				}
+				if !found || !matchDocument(doc, filter) {
+					continue
 				}
-			}()
-		}
-
-		// Send documents to workers
-		for _, doc := range results {
-			docCh <- doc
-		}
-
-		close(docCh) // Close the doc channel when all documents are sent
-
-		// Wait for all workers to finish
-		wg.Wait()
-
-		close(errCh) // Close the error channel
 
-		// Collect and return any errors
-		var finalErr error
-		for err := range errCh {
-			if finalErr == nil {
-				finalErr = err
-			} else {
-				finalErr = fmt.Errorf("%v; %v", finalErr, err)
+				if err := nativeDelete(c, txn, docID); err != nil {
+					return 0, fmt.Errorf("failed to delete doc %s: %w", docID, err)
+				}
+				deleted++
 			}
-		}
-
-		return finalErr
+			return deleted, nil
+		})
+	}
+
+	var deleted int
+	err := c.update(func(txn *badger.Txn) error {
+		var err error
+		deleted, err = nativeDeleteMany(c, txn, filter)
+		return err
 	})
+	if err != nil {
+		return 0, err
+	}
+
+	if options.Progress != nil && deleted > 0 {
+		options.Progress(Progress{Processed: deleted, Total: deleted})
+	}
+	return deleted, nil
 }
@@ -0,0 +1,35 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+func TestDeleteRemovesIndexEntries(t *testing.T) {
+	db := testutil.OpenDB(t)
+	products := core.NewCollection[*product](db, "products", core.CollectionOptions{Indexes: []string{"name"}})
+	if err := products.Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := products.DeleteByID("p1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dump lists the fields that have index entries
+	dir := t.TempDir()
+	if err := db.Dump(dir); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := os.ReadFile(filepath.Join(dir, "products.metadata.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(metadata), "name_1") {
+		t.Errorf("index entries left after the delete: %s", metadata)
+	}
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// ErrCollectionDropped is returned by the methods of a dropped collection.
+var ErrCollectionDropped = errors.New("collection has been dropped")
+
+// Truncate deletes every document of the collection together with its index
+// entries, edges and kept versions, keeping the collection and its name. It fails with
+// ErrReferenced if documents of other collections still reference it, since
+// their OnDelete policies would be skipped. Truncate is not transactional and
+// cannot run inside WithTx.
+func (c *Collection[T]) Truncate() error {
+	if err := c.checkDroppable("truncate"); err != nil {
+		return err
+	}
+	return c.dropData()
+}
+
+// Drop deletes the collection: its documents, index entries, edges and kept
+// versions, then its catalog entry together with the rules of references from
+// and to it, so the name can be reused. Like Truncate it fails with
+// ErrReferenced while other documents reference it. The data is dropped
+// before the catalog entry, so if Drop fails part way the collection is still
+// there and Drop can be called again. Any other handle on the collection must
+// not be used afterwards.
+func (c *Collection[T]) Drop() error {
+	if err := c.checkDroppable("drop"); err != nil {
+		return err
+	}
+
+	if err := c.dropData(); err != nil {
+		return err
+	}
+	if err := c.db.removeFromCatalog(c.id); err != nil {
+		return err
+	}
+
+	c.err = ErrCollectionDropped
+	return nil
+}
+
+// checkDroppable reports why the collection's data can't be dropped, if it can't.
+func (c *Collection[T]) checkDroppable(action string) error {
+	if err := c.writable(); err != nil {
+		return err
+	}
+	if c.tx != nil {
+		return fmt.Errorf("cannot %s a collection inside a transaction", action)
+	}
+
+	return c.Db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		prefix := []byte(collectionPrefix(refPrefix, c.id))
+		iter.Seek(prefix)
+		if iter.ValidForPrefix(prefix) {
+			return fmt.Errorf("cannot %s %s: %w by other collections", action, c.Name, ErrReferenced)
+		}
+		return nil
+	})
+}
+
+// dropData deletes every key of the collection, and the reverse references
+// its documents hold into other collections.
+func (c *Collection[T]) dropData() error {
+	if err := c.dropReferencesFrom(); err != nil {
+		return err
+	}
+
+	return c.Db.DropPrefix(
+		[]byte(c.nodePrefix),
+		[]byte(c.idxPrefix),
+		[]byte(c.edgePrefix),
+		[]byte(c.historyPrefix),
+		[]byte(collectionPrefix(refPrefix, c.id)),
+	)
+}
+
+// dropReferencesFrom deletes the reverse-reference keys whose source is the
+// collection. They live in the key ranges of the referenced collections.
+func (c *Collection[T]) dropReferencesFrom() error {
+	source := encodeCollectionID(c.id)
+
+	wb := c.Db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, target := range c.refTargets {
+		err := c.Db.View(func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			iter := txn.NewIterator(opts)
+			defer iter.Close()
+
+			prefix := []byte(collectionPrefix(refPrefix, target))
+			for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+				// The source collection ID follows the referenced document's ID
+				key := iter.Item().KeyCopy(nil)
+				rest := key[len(prefix):]
+				end := bytes.Index(rest, []byte(keySeparator))
+				if end < 0 || !bytes.HasPrefix(rest[end+1:], source) {
+					continue
+				}
+				if err := wb.Delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
@@ -0,0 +1,153 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+// collectionNames returns the sorted names of the collections of db.
+func collectionNames(db *core.DB) string {
+	names := db.CollectionNames()
+	sort.Strings(names)
+	return fmt.Sprint(names)
+}
+
+func TestTruncate(t *testing.T) {
+	db := testutil.OpenDB(t)
+	products := core.NewCollection[*product](db, "products", core.CollectionOptions{
+		Indexes:      []string{"name"},
+		EdgeLabels:   []string{"related"},
+		KeepVersions: 1,
+	})
+	testutil.Seed(t, products, "testdata/products.json")
+	if err := products.AddEdge("p1", "related", "p2", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := products.Truncate(); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	if got := storedIDs(t, products); got != "[]" {
+		t.Errorf("documents %s left after Truncate", got)
+	}
+	if edges, err := products.OutEdges("p1", "related"); err != nil || len(edges) != 0 {
+		t.Errorf("OutEdges after Truncate = %v, %v, want none", edges, err)
+	}
+	if versions, err := products.History("p1"); err != nil || len(versions) != 0 {
+		t.Errorf("History after Truncate = %v, %v, want none", versions, err)
+	}
+	if found, err := products.Find(core.Filter{"name": "lamp"}); err != nil || len(found) != 0 {
+		t.Errorf("Find by index after Truncate = %v, %v, want none", found, err)
+	}
+
+	// The collection is still there
+	if got := collectionNames(db); got != "[products]" {
+		t.Errorf("collections %s after Truncate, want [products]", got)
+	}
+	if err := products.Insert(&product{ID: "p4", Name: "lamp"}); err != nil {
+		t.Fatalf("Insert after Truncate: %v", err)
+	}
+	if found, err := products.Find(core.Filter{"name": "lamp"}); err != nil || len(found) != 1 {
+		t.Errorf("Find by index after inserting again = %v, %v, want p4", found, err)
+	}
+}
+
+func TestTruncateReferencedCollection(t *testing.T) {
+	db := testutil.OpenDB(t)
+	products := core.NewCollection[*product](db, "products")
+	orders := core.NewCollection[*order](db, "orders", core.CollectionOptions{References: []core.Reference{
+		{Field: "productId", Collection: "products", OnDelete: core.Cascade},
+	}})
+	if err := products.Insert(&product{ID: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := orders.Insert(&order{ID: "o1", ProductID: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := products.Truncate(); !errors.Is(err, core.ErrReferenced) {
+		t.Errorf("Truncate error = %v, want ErrReferenced", err)
+	}
+	if err := products.Drop(); !errors.Is(err, core.ErrReferenced) {
+		t.Errorf("Drop error = %v, want ErrReferenced", err)
+	}
+	if got := storedIDs(t, products); got != "[p1]" {
+		t.Errorf("products %s, want [p1] kept", got)
+	}
+}
+
+func TestDrop(t *testing.T) {
+	dir := t.TempDir()
+	db, options := openOrders(t, dir, core.Restrict)
+	products := core.NewCollection[*product](db, "products")
+	orders := core.NewCollection[*order](db, "orders", options)
+	if err := products.Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := orders.Insert(&order{ID: "o1", ProductID: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := orders.Drop(); err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+	if got := collectionNames(db); got != "[products]" {
+		t.Errorf("collections %s after Drop, want [products]", got)
+	}
+	if _, err := orders.Find(core.Filter{}); !errors.Is(err, core.ErrCollectionDropped) {
+		t.Errorf("Find on the dropped collection = %v, want ErrCollectionDropped", err)
+	}
+	if err := orders.Drop(); !errors.Is(err, core.ErrCollectionDropped) {
+		t.Errorf("second Drop = %v, want ErrCollectionDropped", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither the orders nor the rule of their reference survive reopening
+	db, _ = openOrders(t, dir, core.Restrict)
+	if got := storedIDs(t, core.NewCollection[*order](db, "orders")); got != "[]" {
+		t.Errorf("orders %s in the recreated collection, want none", got)
+	}
+	if err := core.NewCollection[*product](db, "products").DeleteByID("p1"); err != nil {
+		t.Errorf("DeleteByID of a product referenced by a dropped collection: %v", err)
+	}
+}
+
+func TestRenameCollection(t *testing.T) {
+	db := testutil.OpenDB(t)
+	products := core.NewCollection[*product](db, "products")
+	testutil.Seed(t, products, "testdata/products.json")
+	if err := core.NewCollection[*order](db, "orders").Insert(&order{ID: "o1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.RenameCollection("products", "orders"); err == nil {
+		t.Error("renaming onto an existing collection succeeded")
+	}
+	if err := db.RenameCollection("missing", "items"); err == nil {
+		t.Error("renaming a missing collection succeeded")
+	}
+	if err := db.RenameCollection("products", "items"); err != nil {
+		t.Fatalf("RenameCollection: %v", err)
+	}
+
+	if got := collectionNames(db); got != "[items orders]" {
+		t.Errorf("collections %s, want [items orders]", got)
+	}
+	if got := storedIDs(t, core.NewCollection[*product](db, "items")); got != "[p1 p2 p3]" {
+		t.Errorf("renamed collection holds %s, want [p1 p2 p3]", got)
+	}
+	// Handles opened before the rename keep working
+	if got := storedIDs(t, products); got != "[p1 p2 p3]" {
+		t.Errorf("existing handle reads %s, want [p1 p2 p3]", got)
+	}
+	if got := storedIDs(t, core.NewCollection[*product](db, "products")); got != "[]" {
+		t.Errorf("new products collection holds %s, want none", got)
+	}
+}
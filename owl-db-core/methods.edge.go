@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// Edge is a directed, labelled link between two documents of a collection.
+type Edge struct {
+	From  string
+	Label string
+	To    string
+	Props map[string]interface{}
+}
+
+// otherEnd returns the node at the opposite end of the edge from id.
+func (e Edge) otherEnd(id string) string {
+	if e.From == id {
+		return e.To
+	}
+	return e.From
+}
+
+// AddEdge links fromID to toID with a label declared in EdgeLabels. Adding
+// an edge that already exists replaces its properties.
+func (c *Collection[T]) AddEdge(fromID, label, toID string, props map[string]interface{}) error {
+	if err := c.checkEdgeLabel(label); err != nil {
+		return err
+	}
+
+	return c.update(func(txn *badger.Txn) error {
+		return nativeAddEdge(c, txn, fromID, label, toID, props)
+	})
+}
+
+func (c *Collection[T]) RemoveEdge(fromID, label, toID string) error {
+	if err := c.checkEdgeLabel(label); err != nil {
+		return err
+	}
+
+	return c.update(func(txn *badger.Txn) error {
+		found, err := nativeRemoveEdge(c, txn, fromID, label, toID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("edge %s -%s-> %s not found", fromID, label, toID)
+		}
+		return nil
+	})
+}
+
+// OutEdges returns the edges starting at id. An empty label returns edges of
+// every label.
+func (c *Collection[T]) OutEdges(id, label string) ([]Edge, error) {
+	return c.edges(outEdgeKey, id, label)
+}
+
+// InEdges returns the edges ending at id. An empty label returns edges of
+// every label.
+func (c *Collection[T]) InEdges(id, label string) ([]Edge, error) {
+	return c.edges(inEdgeKey, id, label)
+}
+
+func (c *Collection[T]) edges(direction, id, label string) ([]Edge, error) {
+	if label != "" {
+		if err := c.checkEdgeLabel(label); err != nil {
+			return nil, err
+		}
+	}
+
+	var edges []Edge
+	err := c.view(func(txn *badger.Txn) error {
+		var err error
+		edges, err = nativeEdges(c, txn, direction, id, label)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return edges, nil
+}
@@ -0,0 +1,137 @@
+package core_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+// graphEdge is an edge to add to a test graph, with its properties.
+type graphEdge struct {
+	from, label, to string
+	props           map[string]interface{}
+}
+
+// openGraph stores a product for each of ids in the nodes collection of db
+// and links them with edges. Edges may use the labels "road" and "rail".
+func openGraph(t *testing.T, db *core.DB, ids string, edges []graphEdge) *core.Collection[*product] {
+	t.Helper()
+
+	nodes := core.NewCollection[*product](db, "nodes", core.CollectionOptions{
+		EdgeLabels: []string{"road", "rail"},
+	})
+	for _, id := range strings.Fields(ids) {
+		if err := nodes.Insert(&product{ID: id, Name: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, edge := range edges {
+		if err := nodes.AddEdge(edge.from, edge.label, edge.to, edge.props); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nodes
+}
+
+// formatEdges lists edges as from-label->to, in order.
+func formatEdges(edges []core.Edge) string {
+	var formatted []string
+	for _, edge := range edges {
+		formatted = append(formatted, fmt.Sprintf("%s-%s->%s", edge.From, edge.Label, edge.To))
+	}
+	return strings.Join(formatted, " ")
+}
+
+func TestEdges(t *testing.T) {
+	nodes := openGraph(t, testutil.OpenDB(t), "a b c", []graphEdge{
+		{"a", "road", "b", map[string]interface{}{"km": 5}},
+		{"a", "rail", "c", nil},
+		{"c", "road", "a", nil},
+	})
+
+	for _, test := range []struct {
+		name  string
+		list  func(id, label string) ([]core.Edge, error)
+		id    string
+		label string
+		want  string
+	}{
+		{"out of a", nodes.OutEdges, "a", "", "a-rail->c a-road->b"},
+		{"road out of a", nodes.OutEdges, "a", "road", "a-road->b"},
+		{"into a", nodes.InEdges, "a", "", "c-road->a"},
+		{"into b", nodes.InEdges, "b", "road", "a-road->b"},
+		{"out of b", nodes.OutEdges, "b", "", ""},
+	} {
+		edges, err := test.list(test.id, test.label)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := formatEdges(edges); got != test.want {
+			t.Errorf("%s: edges %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	// Adding an edge again replaces its properties
+	if err := nodes.AddEdge("a", "road", "b", map[string]interface{}{"km": 7}); err != nil {
+		t.Fatal(err)
+	}
+	edges, err := nodes.InEdges("b", "road")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edges) != 1 || fmt.Sprint(edges[0].Props["km"]) != "7" {
+		t.Errorf("edges into b after replacing = %v, want one with km 7", edges)
+	}
+
+	if err := nodes.RemoveEdge("a", "road", "b"); err != nil {
+		t.Fatalf("RemoveEdge: %v", err)
+	}
+	if edges, err := nodes.InEdges("b", ""); err != nil || len(edges) != 0 {
+		t.Errorf("edges into b after RemoveEdge = %v, %v, want none", edges, err)
+	}
+
+	if err := nodes.RemoveEdge("a", "road", "b"); err == nil {
+		t.Error("removing a missing edge succeeded")
+	}
+	if err := nodes.AddEdge("a", "air", "b", nil); err == nil {
+		t.Error("adding an edge with an undeclared label succeeded")
+	}
+	if err := nodes.AddEdge("a", "road", "z", nil); err == nil {
+		t.Error("adding an edge to a missing document succeeded")
+	}
+}
+
+func TestDeleteRemovesEdges(t *testing.T) {
+	db := testutil.OpenDB(t)
+	nodes := openGraph(t, db, "a b c", []graphEdge{
+		{"a", "road", "b", nil},
+		{"b", "road", "c", nil},
+		{"c", "rail", "a", nil},
+	})
+
+	// A handle that declares no labels still removes the node's edges
+	plain := core.NewCollection[*product](db, "nodes")
+	if err := plain.DeleteByID("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"a", "c"} {
+		out, err := nodes.OutEdges(id, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		in, err := nodes.InEdges(id, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := formatEdges(append(out, in...)); strings.Contains(got, "b") {
+			t.Errorf("edges of %s after deleting b: %s", id, got)
+		}
+	}
+	if out, err := nodes.OutEdges("c", "rail"); err != nil || len(out) != 1 {
+		t.Errorf("edge c-rail->a = %v, %v, want it kept", out, err)
+	}
+}
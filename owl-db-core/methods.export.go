@@ -0,0 +1,301 @@
+package core
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrNoFields = errors.New("CSV needs a field list")
+
+// Format is a file format collections are exported to and imported from.
+// The JSON formats hold one document per line, like the files of
+// mongoexport and mongoimport.
+type Format int
+
+const (
+	// JSONFormat is plain JSON. ObjectIDs and dates become strings, so it
+	// suits other tools better than round trips.
+	JSONFormat Format = iota
+	// RelaxedExtJSONFormat is MongoDB Extended JSON in relaxed mode, the
+	// default of mongoexport: ObjectIDs and dates keep their types and
+	// numbers stay readable.
+	RelaxedExtJSONFormat
+	// CanonicalExtJSONFormat is Extended JSON in canonical mode, which also
+	// keeps the exact type of every number.
+	CanonicalExtJSONFormat
+	// CSVFormat is a header line followed by one row per document, holding
+	// the fields named in the options.
+	CSVFormat
+	// BSONFormat is the documents' BSON one after another, as in the .bson
+	// files of mongodump. It keeps every type exactly.
+	BSONFormat
+)
+
+var formatNames = map[Format]string{
+	JSONFormat:             "json",
+	RelaxedExtJSONFormat:   "relaxed",
+	CanonicalExtJSONFormat: "canonical",
+	CSVFormat:              "csv",
+	BSONFormat:             "bson",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// ParseFormat returns the format with the given name: json, relaxed,
+// canonical, csv or bson.
+func ParseFormat(name string) (Format, error) {
+	for format, formatName := range formatNames {
+		if formatName == name {
+			return format, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown format %q, expected json, relaxed, canonical, csv or bson", name)
+}
+
+type ExportOptions struct {
+	Filter Filter   // Export only the matching documents, all of them if nil
+	Fields []string // CSV only: the columns, as dotted paths, in order
+}
+
+// Export writes the documents of the collection to w in the given format and
+// returns how many it wrote. The documents are read in one transaction, so
+// they are a consistent view of the collection.
+func (c *Collection[T]) Export(w io.Writer, format Format, opts ...ExportOptions) (int, error) {
+	var options ExportOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	exporter, err := newExporter(w, format, options.Fields)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = c.view(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		prefix := []byte(c.nodePrefix)
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			err := iter.Item().Value(func(val []byte) error {
+				if len(options.Filter) > 0 {
+					var doc map[string]interface{}
+					if err := bson.Unmarshal(val, &doc); err != nil {
+						return err
+					}
+					if !matchDocument(doc, options.Filter) {
+						return nil
+					}
+				}
+
+				count++
+				return exporter.write(val)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, exporter.flush()
+}
+
+// exporter encodes BSON documents to one of the export formats.
+type exporter struct {
+	format Format
+	fields []string
+	out    *bufio.Writer
+	csv    *csv.Writer
+}
+
+func newExporter(w io.Writer, format Format, fields []string) (*exporter, error) {
+	e := &exporter{format: format, fields: fields}
+
+	switch format {
+	case JSONFormat, RelaxedExtJSONFormat, CanonicalExtJSONFormat, BSONFormat:
+		e.out = bufio.NewWriter(w)
+	case CSVFormat:
+		if len(fields) == 0 {
+			return nil, ErrNoFields
+		}
+		e.csv = csv.NewWriter(w)
+		if err := e.csv.Write(fields); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %v", format)
+	}
+	return e, nil
+}
+
+func (e *exporter) write(raw []byte) error {
+	var line []byte
+	var err error
+
+	switch e.format {
+	case JSONFormat:
+		var doc bson.D
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return err
+		}
+		line, err = appendPlainJSON(nil, doc)
+	case RelaxedExtJSONFormat, CanonicalExtJSONFormat:
+		line, err = bson.MarshalExtJSON(bson.Raw(raw), e.format == CanonicalExtJSONFormat, false)
+	case CSVFormat:
+		return e.writeRecord(raw)
+	case BSONFormat:
+		_, err := e.out.Write(raw)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := e.out.Write(line); err != nil {
+		return err
+	}
+	return e.out.WriteByte('\n')
+}
+
+// writeRecord writes the fields of a document as a CSV row. Missing fields
+// are left blank and embedded documents and arrays are written as JSON.
+func (e *exporter) writeRecord(raw []byte) error {
+	var doc map[string]interface{}
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+
+	record := make([]string, len(e.fields))
+	for i, field := range e.fields {
+		value, exists := getNestedValue(doc, strings.Split(field, "."))
+		if !exists {
+			continue
+		}
+
+		var err error
+		record[i], err = csvValue(value)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field, err)
+		}
+	}
+	return e.csv.Write(record)
+}
+
+func (e *exporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+	return e.out.Flush()
+}
+
+// csvValue formats a field for a CSV cell.
+func csvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case primitive.ObjectID:
+		return v.Hex(), nil
+	case primitive.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano), nil
+	case bson.D, bson.M, map[string]interface{}, primitive.A, []interface{}:
+		encoded, err := appendPlainJSON(nil, v)
+		return string(encoded), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// appendPlainJSON appends a decoded BSON value to b as plain JSON, keeping
+// the order of the fields of ordered documents.
+func appendPlainJSON(b []byte, value interface{}) ([]byte, error) {
+	var err error
+
+	switch v := value.(type) {
+	case bson.D:
+		b = append(b, '{')
+		for i, elem := range v {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = appendJSONField(b, elem.Key, elem.Value); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, '}'), nil
+	case bson.M:
+		return appendPlainJSON(b, map[string]interface{}(v))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		b = append(b, '{')
+		for i, key := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = appendJSONField(b, key, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, '}'), nil
+	case primitive.A:
+		return appendPlainJSON(b, []interface{}(v))
+	case []interface{}:
+		b = append(b, '[')
+		for i, elem := range v {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = appendPlainJSON(b, elem); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	case primitive.ObjectID:
+		value = v.Hex()
+	case primitive.DateTime:
+		value = v.Time().UTC().Format(time.RFC3339Nano)
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, encoded...), nil
+}
+
+func appendJSONField(b []byte, key string, value interface{}) ([]byte, error) {
+	encodedKey, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	b = append(append(b, encodedKey...), ':')
+	return appendPlainJSON(b, value)
+}
@@ -0,0 +1,422 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// removeValue is returned by the $$REMOVE variable; assigning it removes the field.
+type removeValue struct{}
+
+// evaluateExpression evaluates an aggregation expression against a document.
+//
+//	"$price"                                  -> value of the price field
+//	{"$multiply": []interface{}{"$price", "$qty"}} -> price * qty
+//	{"$literal": "$notAFieldPath"}            -> the string itself
+func evaluateExpression(expr interface{}, doc map[string]interface{}) (interface{}, error) {
+	switch e := expr.(type) {
+	case string:
+		if strings.HasPrefix(e, "$$") {
+			return evaluateVariable(e, doc)
+		}
+		if strings.HasPrefix(e, "$") {
+			value, _ := getNestedValue(doc, strings.Split(e[1:], "."))
+			return copyValue(value), nil
+		}
+		return e, nil
+
+	case map[string]interface{}:
+		return evaluateObjectExpression(e, doc)
+
+	case Filter:
+		return evaluateObjectExpression(e, doc)
+
+	case []interface{}, primitive.A:
+		array, _ := expressionArray(e)
+		values := make([]interface{}, len(array))
+		for i, element := range array {
+			value, err := evaluateExpression(element, doc)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+
+	// Numbers, booleans, dates etc. are literals
+	return expr, nil
+}
+
+// expressionArray returns the elements of an array in an expression, which
+// is a primitive.A rather than a []interface{} when it was decoded from BSON.
+func expressionArray(value interface{}) ([]interface{}, bool) {
+	switch array := value.(type) {
+	case []interface{}:
+		return array, true
+	case primitive.A:
+		return array, true
+	}
+	return nil, false
+}
+
+func evaluateVariable(name string, doc map[string]interface{}) (interface{}, error) {
+	variable, path, _ := strings.Cut(name[2:], ".")
+	var value interface{}
+	switch variable {
+	case "ROOT", "CURRENT":
+		value = doc
+	case "NOW":
+		return time.Now(), nil
+	case "REMOVE":
+		return removeValue{}, nil
+	default:
+		return nil, fmt.Errorf("unknown variable %s", name)
+	}
+
+	if path != "" {
+		value, _ = getNestedValue(doc, strings.Split(path, "."))
+	}
+	return copyValue(value), nil
+}
+
+// copyValue deeply copies the documents and arrays of a field value, so that
+// expressions yield values rather than parts of the document they read:
+// storing "$$ROOT" into the document it came from must not make it contain
+// itself.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyDocument(v)
+	case primitive.A:
+		return primitive.A(copyArray(v))
+	case []interface{}:
+		return copyArray(v)
+	}
+	return value
+}
+
+func copyArray(array []interface{}) []interface{} {
+	copied := make([]interface{}, len(array))
+	for i, element := range array {
+		copied[i] = copyValue(element)
+	}
+	return copied
+}
+
+// evaluateObjectExpression evaluates either an operator expression
+// ({"$op": args}) or a document whose fields are expressions.
+func evaluateObjectExpression(e map[string]interface{}, doc map[string]interface{}) (interface{}, error) {
+	if len(e) == 1 {
+		for op, args := range e {
+			if strings.HasPrefix(op, "$") {
+				return evaluateOperator(op, args, doc)
+			}
+		}
+	}
+
+	result := make(map[string]interface{}, len(e))
+	for field, fieldExpr := range e {
+		if strings.HasPrefix(field, "$") {
+			return nil, fmt.Errorf("operator %s must be the only field of its expression", field)
+		}
+		value, err := evaluateExpression(fieldExpr, doc)
+		if err != nil {
+			return nil, err
+		}
+		if _, remove := value.(removeValue); !remove {
+			result[field] = value
+		}
+	}
+	return result, nil
+}
+
+func evaluateOperator(op string, rawArgs interface{}, doc map[string]interface{}) (interface{}, error) {
+	if op == "$literal" {
+		return rawArgs, nil
+	}
+
+	// Operators take a single argument or an array of arguments
+	var args []interface{}
+	if array, ok := expressionArray(rawArgs); ok {
+		args = make([]interface{}, len(array))
+		for i, arg := range array {
+			value, err := evaluateExpression(arg, doc)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = value
+		}
+	} else if op != "$cond" {
+		value, err := evaluateExpression(rawArgs, doc)
+		if err != nil {
+			return nil, err
+		}
+		args = []interface{}{value}
+	}
+
+	switch op {
+	case "$add", "$multiply":
+		var result interface{} = int32(0)
+		if op == "$multiply" {
+			result = int32(1)
+		}
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+			// Adding a number to a date moves the date by that many milliseconds
+			if t, ok := toTime(result); ok && op == "$add" {
+				ms, _, _, isNumber := toNumber(arg)
+				if !isNumber {
+					return nil, fmt.Errorf("$add only supports numeric or date types")
+				}
+				result = t.Add(time.Duration(ms) * time.Millisecond)
+				continue
+			}
+			if t, ok := toTime(arg); ok && op == "$add" {
+				ms, _, _, _ := toNumber(result)
+				result = t.Add(time.Duration(ms) * time.Millisecond)
+				continue
+			}
+
+			var err error
+			if op == "$add" {
+				result, err = addNumbers(result, arg)
+			} else {
+				result, err = multiplyNumbers(result, arg)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", op, err)
+			}
+		}
+		return result, nil
+
+	case "$subtract":
+		if err := expectArgs(op, args, 2); err != nil {
+			return nil, err
+		}
+		if args[0] == nil || args[1] == nil {
+			return nil, nil
+		}
+		if a, ok := toTime(args[0]); ok {
+			if b, ok := toTime(args[1]); ok {
+				return a.Sub(b).Milliseconds(), nil
+			}
+			ms, _, _, isNumber := toNumber(args[1])
+			if !isNumber {
+				return nil, fmt.Errorf("$subtract only supports subtracting a number or a date from a date")
+			}
+			return a.Add(-time.Duration(ms) * time.Millisecond), nil
+		}
+		negated, err := multiplyNumbers(args[1], int32(-1))
+		if err != nil {
+			return nil, fmt.Errorf("$subtract: %v", err)
+		}
+		return addNumbers(args[0], negated)
+
+	case "$divide", "$mod":
+		if err := expectArgs(op, args, 2); err != nil {
+			return nil, err
+		}
+		if args[0] == nil || args[1] == nil {
+			return nil, nil
+		}
+		ai, af, aKind, ok := toNumber(args[0])
+		bi, bf, bKind, ok2 := toNumber(args[1])
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("%s only supports numeric types", op)
+		}
+		if bf == 0 {
+			return nil, fmt.Errorf("%s by zero", op)
+		}
+		if op == "$divide" {
+			return af / bf, nil
+		}
+		if max(aKind, bKind) == numberFloat64 {
+			return math.Mod(af, bf), nil
+		}
+		return narrowIntKind(ai%bi, max(aKind, bKind)), nil
+
+	case "$abs":
+		if err := expectArgs(op, args, 1); err != nil {
+			return nil, err
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		i, f, kind, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("$abs only supports numeric types")
+		}
+		if kind == numberFloat64 {
+			return math.Abs(f), nil
+		}
+		if i < 0 {
+			i = -i
+		}
+		return narrowIntKind(i, kind), nil
+
+	case "$concat":
+		var builder strings.Builder
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+			s, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("$concat only supports strings, not %T", arg)
+			}
+			builder.WriteString(s)
+		}
+		return builder.String(), nil
+
+	case "$toUpper", "$toLower", "$toString":
+		if err := expectArgs(op, args, 1); err != nil {
+			return nil, err
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		s := fmt.Sprintf("%v", args[0])
+		switch op {
+		case "$toUpper":
+			return strings.ToUpper(s), nil
+		case "$toLower":
+			return strings.ToLower(s), nil
+		}
+		return s, nil
+
+	case "$size":
+		if err := expectArgs(op, args, 1); err != nil {
+			return nil, err
+		}
+		array, ok := toArray(args[0])
+		if !ok {
+			return nil, fmt.Errorf("the argument to $size must be an array")
+		}
+		return int32(len(array)), nil
+
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$cmp":
+		if err := expectArgs(op, args, 2); err != nil {
+			return nil, err
+		}
+		result, comparable := compareValues(args[0], args[1])
+		if !comparable {
+			// Different types only compare equal if they are deeply equal
+			result = 1
+			if valuesEqual(args[0], args[1]) {
+				result = 0
+			}
+		}
+		switch op {
+		case "$eq":
+			return result == 0, nil
+		case "$ne":
+			return result != 0, nil
+		case "$gt":
+			return result > 0, nil
+		case "$gte":
+			return result >= 0, nil
+		case "$lt":
+			return result < 0, nil
+		case "$lte":
+			return result <= 0, nil
+		}
+		return int32(result), nil
+
+	case "$and":
+		for _, arg := range args {
+			if !truthy(arg) {
+				return false, nil
+			}
+		}
+		return true, nil
+
+	case "$or":
+		for _, arg := range args {
+			if truthy(arg) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "$not":
+		if err := expectArgs(op, args, 1); err != nil {
+			return nil, err
+		}
+		return !truthy(args[0]), nil
+
+	case "$cond":
+		return evaluateCond(rawArgs, doc)
+
+	case "$ifNull":
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported expression operator: %s", op)
+	}
+}
+
+// evaluateCond evaluates only the selected branch of
+// {"$cond": [if, then, else]} or {"$cond": {"if": ..., "then": ..., "else": ...}}.
+func evaluateCond(rawArgs interface{}, doc map[string]interface{}) (interface{}, error) {
+	var ifExpr, thenExpr, elseExpr interface{}
+	if args, ok := expressionArray(rawArgs); ok {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("$cond requires 3 arguments")
+		}
+		ifExpr, thenExpr, elseExpr = args[0], args[1], args[2]
+	} else if args, ok := rawArgs.(map[string]interface{}); ok {
+		ifExpr, thenExpr, elseExpr = args["if"], args["then"], args["else"]
+	} else {
+		return nil, fmt.Errorf("invalid $cond expression")
+	}
+
+	condition, err := evaluateExpression(ifExpr, doc)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(condition) {
+		return evaluateExpression(thenExpr, doc)
+	}
+	return evaluateExpression(elseExpr, doc)
+}
+
+func expectArgs(op string, args []interface{}, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s requires %d argument(s), got %d", op, n, len(args))
+	}
+	return nil
+}
+
+// truthy follows the aggregation rules: null, false and zero are false.
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	}
+	if _, f, _, ok := toNumber(value); ok {
+		return f != 0
+	}
+	return true
+}
+
+// narrowIntKind keeps integer results in the kind of their operands.
+func narrowIntKind(v int64, kind int) interface{} {
+	if kind == numberInt32 {
+		return narrowInt(v)
+	}
+	return v
+}
@@ -2,13 +2,16 @@ package core
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	badger "github.com/dgraph-io/badger/v4"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Filter represents a query filter
@@ -30,9 +33,9 @@ type SortField struct {
 func (c *Collection[T]) FindByID(docID string) (map[string]interface{}, error) {
 	var result map[string]interface{}
 
-	err := c.Db.View(func(txn *badger.Txn) error {
-		key := fmt.Sprintf("%s|%s", c.Name, docID)
-		item, err := txn.Get([]byte(key))
+	err := c.view(func(txn *badger.Txn) error {
+		key := c.docKey(docID)
+		item, err := txn.Get(key)
 		if err != nil {
 			return err
 		}
@@ -62,7 +65,7 @@ type FoundDocStruct struct {
 func (c *Collection[T]) FindOne(filter Filter) (map[string]interface{}, error) {
 	var result FoundDocStruct
 
-	err := c.Db.View(func(txn *badger.Txn) error {
+	err := c.view(func(txn *badger.Txn) error {
 		result = nativeFindOne(c, txn, filter)
 		return nil
 	})
@@ -81,7 +84,7 @@ func (c *Collection[T]) Find(filter Filter, findOptions ...FindOptions) ([]map[s
 		options = findOptions[0]
 	}
 
-	err := c.Db.View(func(txn *badger.Txn) error {
+	err := c.view(func(txn *badger.Txn) error {
 		results = nativeFind(c, txn, filter, options)
 		return nil
 	})
@@ -143,77 +146,185 @@ func matchDocument(doc map[string]interface{}, filters Filter) bool {
 
 // applyOperator applies a filter condition on a document field
 func applyOperator(doc map[string]interface{}, field string, value interface{}) bool {
-	keys := strings.Split(field, ".")
-	fieldValue := getFindNestedValue(doc, keys)
+	matched, _ := matchPath(doc, strings.Split(field, "."), value)
+	return matched
+}
+
+// matchPath applies a condition to the value at a dotted path. When the path
+// crosses an array, the condition holds if any element satisfies the rest of
+// the path, and the index of that element is returned for the positional $
+// update operator (-1 when no array was involved).
+func matchPath(current interface{}, keys []string, condition interface{}) (bool, int) {
+	for i, key := range keys {
+		if array, ok := toArray(current); ok {
+			if _, err := strconv.Atoi(key); err != nil {
+				for index, element := range array {
+					if matched, _ := matchPath(element, keys[i:], condition); matched {
+						return true, index
+					}
+				}
+				return false, -1
+			}
+		}
+		current, _ = childValue(current, key)
+	}
 
+	if applyCondition(current, condition) {
+		return true, -1
+	}
+
+	// A condition on an array field also matches against its elements
+	if array, ok := toArray(current); ok {
+		for index, element := range array {
+			if applyCondition(element, condition) {
+				return true, index
+			}
+		}
+	}
+	return false, -1
+}
+
+// applyCondition applies a filter condition to a single field value
+func applyCondition(fieldValue interface{}, value interface{}) bool {
 	switch v := value.(type) {
 	case Filter:
 		for op, opVal := range v {
+			var matched bool
 			switch op {
 			case "$gt":
-				return compare(fieldValue, opVal) > 0
+				matched = compare(fieldValue, opVal) > 0
 			case "$lt":
-				return compare(fieldValue, opVal) < 0
+				matched = compare(fieldValue, opVal) < 0
 			case "$gte":
-				return compare(fieldValue, opVal) >= 0
+				matched = compare(fieldValue, opVal) >= 0
 			case "$lte":
-				return compare(fieldValue, opVal) <= 0
+				matched = compare(fieldValue, opVal) <= 0
 			case "$in":
-				return isIn(fieldValue, opVal)
+				matched = isIn(fieldValue, opVal)
 			case "$nin":
-				return !isIn(fieldValue, opVal)
+				matched = !isIn(fieldValue, opVal)
 			case "$ne":
-				return !reflect.DeepEqual(fieldValue, opVal)
+				matched = !valuesEqual(fieldValue, opVal)
 			case "$exists":
 				// For $exists, opVal should be a boolean indicating presence or absence
 				exists := fieldValue != nil
-				return reflect.DeepEqual(exists, opVal)
+				matched = reflect.DeepEqual(exists, opVal)
 			case "$type":
 				// For $type, opVal is expected to be a string type name (like "string", "int", etc.)
-				return checkType(fieldValue, opVal)
+				matched = checkType(fieldValue, opVal)
 			case "$regex":
 				// For $regex, opVal should be a regular expression pattern string
-				return applyRegex(fieldValue, opVal)
+				matched = applyRegex(fieldValue, opVal)
 			case "$not":
 				// For $not, opVal is a sub-query that must return false
-				return !applyOperator(doc, field, opVal)
-			default:
+				matched = !applyCondition(fieldValue, opVal)
+			}
+			if !matched {
 				return false
 			}
 		}
+		return true
 	default:
 		// Equality check for simple field queries
-		return reflect.DeepEqual(fieldValue, value)
+		return valuesEqual(fieldValue, value)
 	}
-	return false
 }
 
 // compare compares two values and returns -1, 0, or 1
 func compare(a, b interface{}) int {
-	switch a := a.(type) {
-	case float64:
-		if b, ok := b.(float64); ok {
-			if a < b {
-				return -1
-			} else if a > b {
-				return 1
-			}
-			return 0
-		}
-	case time.Time:
-		if b, ok := b.(time.Time); ok {
-			if a.Before(b) {
-				return -1
-			} else if a.After(b) {
-				return 1
-			}
-			return 0
-		}
+	if result, ok := compareValues(a, b); ok {
+		return result
 	}
 	// Add more type comparisons as needed
 	return -1
 }
 
+// compareValues compares two values of compatible types. Numbers compare
+// across int32/int64/float64 and dates across time.Time/primitive.DateTime.
+// The boolean is false when the values cannot be ordered.
+func compareValues(a, b interface{}) (int, bool) {
+	if ai, af, aKind, ok := toNumber(a); ok {
+		bi, bf, bKind, ok := toNumber(b)
+		if !ok {
+			return 0, false
+		}
+		if aKind != numberFloat64 && bKind != numberFloat64 {
+			return cmpOrdered(ai, bi), true
+		}
+		return cmpOrdered(af, bf), true
+	}
+
+	if at, ok := toTime(a); ok {
+		bt, ok := toTime(b)
+		if !ok {
+			return 0, false
+		}
+		return at.Compare(bt), true
+	}
+
+	if as, ok := a.(string); ok {
+		bs, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(as, bs), true
+	}
+
+	return 0, false
+}
+
+func cmpOrdered[N int64 | float64](a, b N) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Numeric kinds, ordered by how mixed arithmetic widens
+const (
+	numberInt32 = iota + 1
+	numberInt64
+	numberFloat64
+)
+
+// toNumber converts any Go or BSON numeric value to both its integer and
+// float representation along with its numeric kind.
+func toNumber(v interface{}) (int64, float64, int, bool) {
+	switch n := v.(type) {
+	case int8:
+		return int64(n), float64(n), numberInt32, true
+	case int16:
+		return int64(n), float64(n), numberInt32, true
+	case int32:
+		return int64(n), float64(n), numberInt32, true
+	case int:
+		if n >= math.MinInt32 && n <= math.MaxInt32 {
+			return int64(n), float64(n), numberInt32, true
+		}
+		return int64(n), float64(n), numberInt64, true
+	case int64:
+		return n, float64(n), numberInt64, true
+	case float32:
+		return int64(n), float64(n), numberFloat64, true
+	case float64:
+		return int64(n), n, numberFloat64, true
+	}
+	return 0, 0, 0, false
+}
+
+// toTime converts time.Time and primitive.DateTime values to time.Time.
+func toTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case primitive.DateTime:
+		return t.Time(), true
+	}
+	return time.Time{}, false
+}
+
 // checkType checks if the value is of a specific type (e.g., string, int, etc.)
 func checkType(fieldValue interface{}, expectedType interface{}) bool {
 	expectedTypeStr, ok := expectedType.(string)
@@ -263,29 +374,26 @@ func applyRegex(fieldValue interface{}, pattern interface{}) bool {
 
 // isIn checks if a value is in a list
 func isIn(fieldValue interface{}, values interface{}) bool {
-	vals, ok := values.([]interface{})
+	vals, ok := toArray(values)
 	if !ok {
 		return false
 	}
 	for _, v := range vals {
-		if reflect.DeepEqual(fieldValue, v) {
+		if valuesEqual(fieldValue, v) {
 			return true
 		}
 	}
 	return false
 }
 
-// getNestedValue gets the value of a nested field from a document
-func getFindNestedValue(doc map[string]interface{}, keys []string) interface{} {
-	m := doc
-	for _, key := range keys {
-		if nested, ok := m[key].(map[string]interface{}); ok {
-			m = nested
-		} else {
-			return m[key]
-		}
+// valuesEqual compares two values for equality, treating numbers of
+// different types (e.g. int and the int32 BSON decodes to) as equal.
+func valuesEqual(a, b interface{}) bool {
+	if _, _, _, ok := toNumber(a); ok {
+		result, comparable := compareValues(a, b)
+		return comparable && result == 0
 	}
-	return m
+	return reflect.DeepEqual(a, b)
 }
 
 // Function to remove excluded fields from the document by following the full key path
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+var ErrVersionNotKept = errors.New("document version is older than its kept history")
+
+// Change is the kind of write that produced a version of a document.
+type Change string
+
+const (
+	Inserted Change = "insert"
+	Updated  Change = "update"
+	Deleted  Change = "delete"
+)
+
+// Version is a document as one write left it, in a collection created with
+// KeepVersions.
+type Version struct {
+	At     time.Time              // When the write happened
+	Change Change                 // Insert, update (including replace and upsert) or delete
+	Doc    map[string]interface{} // The document after the write, nil for deletions
+}
+
+// History returns the kept versions of a document, oldest first: the current
+// one and at most KeepVersions before it. Older versions are removed as new
+// ones are written. Writes made before KeepVersions was set have no
+// versions.
+func (c *Collection[T]) History(docID string) ([]Version, error) {
+	var versions []Version
+	err := c.view(func(txn *badger.Txn) error {
+		var err error
+		versions, err = nativeVersions(c, txn, docID)
+		return err
+	})
+	return versions, err
+}
+
+// FindByIDAt returns the document as it was at the given time. Like FindByID
+// it fails with badger.ErrKeyNotFound if the document didn't exist then, and
+// with ErrVersionNotKept if that version is older than the kept history.
+func (c *Collection[T]) FindByIDAt(docID string, at time.Time) (map[string]interface{}, error) {
+	var result map[string]interface{}
+
+	err := c.view(func(txn *badger.Txn) error {
+		versions, err := nativeVersions(c, txn, docID)
+		if err != nil {
+			return err
+		}
+
+		// The last version written at or before at
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].At.After(at) }) - 1
+		switch {
+		case i >= 0 && versions[i].Change == Deleted:
+			return badger.ErrKeyNotFound
+		case i >= 0:
+			result = versions[i].Doc
+			return nil
+		case len(versions) > 0 && versions[0].Change == Inserted:
+			return badger.ErrKeyNotFound // Not inserted yet
+		}
+
+		// The version was pruned, or written before versions were kept
+		_, found, err := nativeGet(c, txn, docID)
+		if err != nil {
+			return err
+		}
+		if found || len(versions) > 0 {
+			return ErrVersionNotKept
+		}
+		return badger.ErrKeyNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestHistoryKeepsLatestVersions(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products", core.CollectionOptions{KeepVersions: 2})
+	if err := products.Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+	// Writes in quick succession may share a timestamp but keep their order
+	for qty := 1; qty <= 5; qty++ {
+		if _, err := products.UpdateByID("p1", core.Update{"$set": map[string]interface{}{"qty": qty}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := products.DeleteByID("p1"); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := products.History("p1")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("%d versions kept, want 3: %v", len(versions), versions)
+	}
+	for i, want := range []struct {
+		change core.Change
+		qty    interface{}
+	}{{core.Updated, int32(4)}, {core.Updated, int32(5)}, {core.Deleted, nil}} {
+		if versions[i].Change != want.change || versions[i].Doc["qty"] != want.qty {
+			t.Errorf("version %d = %s %v, want %s with qty %v", i, versions[i].Change, versions[i].Doc, want.change, want.qty)
+		}
+		if i > 0 && versions[i].At.Before(versions[i-1].At) {
+			t.Errorf("version %d at %v is before the previous one at %v", i, versions[i].At, versions[i-1].At)
+		}
+	}
+
+	if _, err := products.FindByIDAt("p1", versions[0].At.Add(-time.Nanosecond)); !errors.Is(err, core.ErrVersionNotKept) {
+		t.Errorf("FindByIDAt before the kept history = %v, want ErrVersionNotKept", err)
+	}
+}
+
+func TestFindByIDAt(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products", core.CollectionOptions{KeepVersions: 10})
+	if err := products.Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := products.UpdateByID("p1", core.Update{"$set": map[string]interface{}{"name": "desk lamp"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := products.DeleteByID("p1"); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := products.History("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("%d versions kept, want 3: %v", len(versions), versions)
+	}
+
+	if _, err := products.FindByIDAt("p1", versions[0].At.Add(-time.Nanosecond)); !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Errorf("FindByIDAt before the insert = %v, want badger.ErrKeyNotFound", err)
+	}
+	if _, err := products.FindByIDAt("p1", versions[2].At); !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Errorf("FindByIDAt after the delete = %v, want badger.ErrKeyNotFound", err)
+	}
+
+	// The last version written at or before each time is found
+	for i := 0; i < 2; i++ {
+		want := i
+		for want+1 < len(versions) && !versions[want+1].At.After(versions[i].At) {
+			want++
+		}
+		if versions[want].Change == core.Deleted {
+			continue
+		}
+
+		doc, err := products.FindByIDAt("p1", versions[i].At)
+		if err != nil {
+			t.Fatalf("FindByIDAt(version %d): %v", i, err)
+		}
+		if doc["name"] != versions[want].Doc["name"] {
+			t.Errorf("FindByIDAt(version %d) = %v, want %v", i, doc, versions[want].Doc)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ImportOptions struct {
+	// CSV only: the columns of the file, in order. If empty, the first line
+	// names them. A column may declare its type like mongoimport's
+	// --columnsHaveTypes, as in "price.double()", "zip.string()" or
+	// "createdAt.date(2006-01-02)"; other values are read as numbers or
+	// booleans when they look like one and as strings otherwise. Blank
+	// values are left out of the document.
+	Fields    []string
+	ChunkSize int // Documents inserted per batch, like InsertMany's Chunked mode
+}
+
+type ImportResult struct {
+	Inserted int
+	Errors   BulkWriteErrors // Documents that could not be decoded or inserted, by position in the input
+}
+
+// Import inserts the documents read from r in the given format, like
+// InsertMany in Chunked mode: a document that can't be decoded into T is
+// reported in the result's Errors, which is also returned as the error,
+// and the others are still inserted. The JSON formats accept a stream of
+// documents or arrays of documents, as written by mongoexport with or
+// without --jsonArray. Documents keep the type of their _id when T can hold
+// it, as RawDocument can: one imported with an ObjectID is found by its hex,
+// as in FindByID(id.Hex()), and exported with the ObjectID again.
+func (c *Collection[T]) Import(r io.Reader, format Format, opts ...ImportOptions) (ImportResult, error) {
+	var options ImportOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	chunkSize := options.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	next, err := newImporter[T](r, format, options.Fields)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	var result ImportResult
+	batch := make([]T, 0, chunkSize)
+	positions := make([]int, 0, chunkSize) // Position in the input of each document of batch
+
+	flush := func() error {
+		inserted, err := c.InsertMany(batch, InsertManyOptions{Atomicity: Chunked, ChunkSize: chunkSize})
+		result.Inserted += len(inserted.InsertedIDs)
+		for _, insertErr := range inserted.Errors {
+			result.Errors = append(result.Errors, BulkWriteError{Index: positions[insertErr.Index], Err: insertErr.Err})
+		}
+		if err != nil && !errors.As(err, new(BulkWriteErrors)) {
+			return err
+		}
+
+		batch, positions = batch[:0], positions[:0]
+		return nil
+	}
+
+	for position := 0; ; position++ {
+		doc, err := next()
+		if err == io.EOF {
+			break
+		}
+		var decodeErr *importDecodeError
+		if errors.As(err, &decodeErr) {
+			result.Errors = append(result.Errors, BulkWriteError{Index: position, Err: decodeErr.err})
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		batch = append(batch, doc)
+		positions = append(positions, position)
+		if len(batch) == chunkSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return result, err
+	}
+
+	if len(result.Errors) > 0 {
+		return result, result.Errors
+	}
+	return result, nil
+}
+
+// importDecodeError wraps the failure to decode one document, after which
+// the import goes on with the next one.
+type importDecodeError struct {
+	err error
+}
+
+func (e *importDecodeError) Error() string {
+	return e.err.Error()
+}
+
+// newImporter returns a function reading the next document from r, or
+// io.EOF at the end of the input.
+func newImporter[T Document](r io.Reader, format Format, fields []string) (func() (T, error), error) {
+	switch format {
+	case JSONFormat, RelaxedExtJSONFormat, CanonicalExtJSONFormat:
+		return jsonImporter[T](r, format == CanonicalExtJSONFormat), nil
+	case CSVFormat:
+		return csvImporter[T](r, fields)
+	case BSONFormat:
+		return bsonImporter[T](r), nil
+	default:
+		return nil, fmt.Errorf("unknown format %v", format)
+	}
+}
+
+func jsonImporter[T Document](r io.Reader, canonical bool) func() (T, error) {
+	decoder := json.NewDecoder(r)
+	var pending []json.RawMessage // Rest of an array of documents
+
+	return func() (T, error) {
+		var doc T
+
+		for len(pending) == 0 {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return doc, err
+			}
+
+			if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+				if err := json.Unmarshal(trimmed, &pending); err != nil {
+					return doc, err
+				}
+				continue
+			}
+			pending = append(pending, raw)
+		}
+
+		raw := pending[0]
+		pending = pending[1:]
+		if err := bson.UnmarshalExtJSON(raw, canonical, &doc); err != nil {
+			return doc, &importDecodeError{err}
+		}
+		return doc, nil
+	}
+}
+
+// maxBSONDocumentSize bounds the documents read from BSON files, to fail
+// fast on input that isn't BSON. MongoDB allows 16MB, plus some headroom.
+const maxBSONDocumentSize = 48 << 20
+
+func bsonImporter[T Document](r io.Reader) func() (T, error) {
+	reader := bufio.NewReader(r)
+
+	return func() (T, error) {
+		var doc T
+
+		var length [4]byte
+		if _, err := io.ReadFull(reader, length[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = errors.New("truncated BSON document")
+			}
+			return doc, err
+		}
+		size := binary.LittleEndian.Uint32(length[:])
+		if size < 5 || size > maxBSONDocumentSize {
+			return doc, fmt.Errorf("invalid BSON document size %d", size)
+		}
+
+		raw := make([]byte, size)
+		copy(raw, length[:])
+		if _, err := io.ReadFull(reader, raw[4:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = errors.New("truncated BSON document")
+			}
+			return doc, err
+		}
+
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return doc, &importDecodeError{err}
+		}
+		return doc, nil
+	}
+}
+
+func csvImporter[T Document](r io.Reader, fields []string) (func() (T, error), error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Rows may leave out trailing blank fields
+
+	if len(fields) == 0 {
+		header, err := reader.Read()
+		if err == io.EOF {
+			return nil, ErrNoFields
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields = header
+	}
+
+	columns := make([]csvColumn, len(fields))
+	for i, field := range fields {
+		var err error
+		if columns[i], err = parseCSVColumn(field); err != nil {
+			return nil, err
+		}
+	}
+
+	return func() (T, error) {
+		var doc T
+
+		record, err := reader.Read()
+		if err != nil {
+			return doc, err
+		}
+
+		var fieldsDoc bson.D
+		for i, cell := range record {
+			if i >= len(columns) {
+				return doc, &importDecodeError{fmt.Errorf("%d values for %d columns", len(record), len(columns))}
+			}
+			if cell == "" {
+				continue
+			}
+
+			value, err := columns[i].value(cell)
+			if err != nil {
+				return doc, &importDecodeError{fmt.Errorf("column %s: %w", fields[i], err)}
+			}
+			fieldsDoc = setDocumentPath(fieldsDoc, columns[i].path, value)
+		}
+
+		raw, err := bson.Marshal(fieldsDoc)
+		if err == nil {
+			err = bson.Unmarshal(raw, &doc)
+		}
+		if err != nil {
+			return doc, &importDecodeError{err}
+		}
+		return doc, nil
+	}, nil
+}
+
+// csvColumnType matches a column with a declared type, such as
+// "createdAt.date(2006-01-02)"
+var csvColumnType = regexp.MustCompile(`^(.+)\.(\w+)\((.*)\)$`)
+
+type csvColumn struct {
+	path     []string
+	kind     string // Declared type, empty to infer it from each value
+	argument string // Layout of dates
+}
+
+func parseCSVColumn(field string) (csvColumn, error) {
+	match := csvColumnType.FindStringSubmatch(field)
+	if match == nil {
+		return csvColumn{path: strings.Split(field, ".")}, nil
+	}
+
+	column := csvColumn{path: strings.Split(match[1], "."), kind: match[2], argument: match[3]}
+	switch column.kind {
+	case "auto":
+		column.kind = ""
+	case "string", "int32", "int64", "double", "decimal", "boolean", "objectId":
+	case "date":
+		if column.argument == "" {
+			column.argument = time.RFC3339Nano
+		}
+	default:
+		return csvColumn{}, fmt.Errorf("column %s: unknown type %s", field, column.kind)
+	}
+	return column, nil
+}
+
+// value converts a cell of the column to a BSON value.
+func (column csvColumn) value(cell string) (interface{}, error) {
+	switch column.kind {
+	case "string":
+		return cell, nil
+	case "int32":
+		n, err := strconv.ParseInt(cell, 10, 32)
+		return int32(n), err
+	case "int64":
+		return strconv.ParseInt(cell, 10, 64)
+	case "double":
+		return strconv.ParseFloat(cell, 64)
+	case "decimal":
+		return primitive.ParseDecimal128(cell)
+	case "boolean":
+		return strconv.ParseBool(cell)
+	case "date":
+		return time.Parse(column.argument, cell)
+	case "objectId":
+		return primitive.ObjectIDFromHex(cell)
+	}
+
+	if n, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		if n >= math.MinInt32 && n <= math.MaxInt32 {
+			return int32(n), nil
+		}
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f, nil
+	}
+	if cell == "true" || cell == "false" {
+		return cell == "true", nil
+	}
+	return cell, nil
+}
+
+// setDocumentPath sets a dotted path of doc, creating the embedded documents
+// on the way.
+func setDocumentPath(doc bson.D, path []string, value interface{}) bson.D {
+	for i := range doc {
+		if doc[i].Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			doc[i].Value = value
+		} else {
+			nested, _ := doc[i].Value.(bson.D)
+			doc[i].Value = setDocumentPath(nested, path[1:], value)
+		}
+		return doc
+	}
+
+	if len(path) == 1 {
+		return append(doc, bson.E{Key: path[0], Value: value})
+	}
+	return append(doc, bson.E{Key: path[0], Value: setDocumentPath(nil, path[1:], value)})
+}
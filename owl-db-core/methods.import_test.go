@@ -0,0 +1,46 @@
+package core_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestImportKeepsObjectIDs(t *testing.T) {
+	products := core.NewCollection[*core.RawDocument](testutil.OpenDB(t), "products")
+
+	id := primitive.NewObjectID()
+	input := `{"_id":{"$oid":"` + id.Hex() + `"},"name":"lamp"}`
+	if _, err := products.Import(strings.NewReader(input), core.RelaxedExtJSONFormat); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	doc, err := products.FindByID(id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["_id"] != id {
+		t.Errorf("_id = %#v, want ObjectID %s", doc["_id"], id.Hex())
+	}
+
+	// Writes find the document by the hex of its _id
+	if _, err := products.UpdateMany(core.Filter{"name": "lamp"}, core.Update{"$set": map[string]interface{}{"price": 12.0}}); err != nil {
+		t.Fatalf("UpdateMany: %v", err)
+	}
+
+	var out bytes.Buffer
+	if _, err := products.Export(&out, core.RelaxedExtJSONFormat); err != nil {
+		t.Fatal(err)
+	}
+	if want := `"_id":{"$oid":"` + id.Hex() + `"}`; !strings.Contains(out.String(), want) {
+		t.Errorf("export = %s, want it to contain %s", out.String(), want)
+	}
+
+	if deleted, err := products.DeleteMany(core.Filter{"name": "lamp"}); err != nil || deleted != 1 {
+		t.Errorf("DeleteMany = %d, %v, want 1 deleted", deleted, err)
+	}
+}
@@ -1,101 +1,145 @@
 package core
 
 import (
-	"fmt"
+	"errors"
 
 	badger "github.com/dgraph-io/badger/v4"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrDuplicateID is returned when inserting a document whose _id is already
+// in a versioned collection. Use an update or upsert to replace a document.
+var ErrDuplicateID = errors.New("document with this _id already exists")
+
+type InsertManyOptions struct {
+	// AllOrNothing (the default) inserts every document in one transaction.
+	// Documents that don't fit in one are inserted as with Chunked instead,
+	// except inside WithTx where the insert fails with badger.ErrTxnTooBig.
+	// Chunked commits the documents in bounded transactions one after
+	// another, so it is not atomic but has no size limit.
+	Atomicity Atomicity
+	ChunkSize int            // Chunked only: documents per transaction, 1000 if zero
+	Progress  func(Progress) // Chunked only: called after each committed chunk
+}
+
+type InsertManyResult struct {
+	InsertedIDs []string
+	Errors      BulkWriteErrors // Documents that could not be encoded or are duplicates, by index in docs
+}
+
 func (c *Collection[T]) Insert(doc T) error {
-	// Check if the document already has an ID
-	if doc.GetID() == "" {
-		// Generate a unique document ID and set it
-		doc.SetID(primitive.NewObjectID().Hex())
-	}
+	return c.update(func(txn *badger.Txn) error {
+		return nativeInsert(c, txn, doc)
+	})
+}
 
-	return c.Db.Update(func(txn *badger.Txn) error {
-		docID := doc.GetID()
-		key := fmt.Sprintf("%s|%s", c.Name, docID)
+// InsertMany inserts docs, generating IDs and setting timestamps like Insert.
+// Documents that cannot be encoded, or whose _id is already in a versioned
+// collection (ErrDuplicateID), are reported in the result's Errors, which
+// is also returned as the error. In AllOrNothing mode any such failure
+// cancels the whole insert; in Chunked mode the other documents are still
+// written.
+func (c *Collection[T]) InsertMany(docs []T, opts ...InsertManyOptions) (InsertManyResult, error) {
+	var options InsertManyOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
 
-		if c.Timestamp {
-			doc.SetCreatedAt()
-		}
+	// Inside WithTx every document is written in the bound transaction, so
+	// Chunked has no effect there
+	if options.Atomicity == Chunked && c.tx == nil {
+		return c.insertManyChunked(docs, options)
+	}
 
-		// Serialize the document
-		serializedDoc, err := bson.Marshal(doc)
+	var result InsertManyResult
+	entries := make([][]*badger.Entry, len(docs))
+	for i, doc := range docs {
+		var err error
+		entries[i], err = insertEntries(c, doc)
 		if err != nil {
-			return err
-		}
-
-		// Store the document in Badger
-		if err := txn.Set([]byte(key), serializedDoc); err != nil {
-			return err
+			result.Errors = append(result.Errors, BulkWriteError{Index: i, Err: err})
 		}
+	}
+	if len(result.Errors) > 0 {
+		return result, result.Errors
+	}
 
-		// Get the indexable fields
-		indexableFields := getIndexableFields(doc, c.Indexes)
-
-		// Update indexes for the indexable fields
-		for field, value := range indexableFields {
-			indexKey := fmt.Sprintf("%s|%s|%v|%s", c.Name, field, value, docID)
-			if err := txn.Set([]byte(indexKey), []byte(docID)); err != nil {
+	// Perform the batch insert operation in a single transaction
+	err := c.update(func(txn *badger.Txn) error {
+		result.Errors = nil
+		for i, doc := range docs {
+			err := nativeWriteInsert(c, txn, doc.GetID(), entries[i])
+			if errors.Is(err, ErrDuplicateID) {
+				result.Errors = append(result.Errors, BulkWriteError{Index: i, Err: err})
+				continue
+			}
+			if err != nil {
 				return err
 			}
 		}
-
+		if len(result.Errors) > 0 {
+			return result.Errors
+		}
 		return nil
 	})
-}
-
-func (c *Collection[T]) InsertMany(docs []T) error {
-	// Function to insert a single document in the transaction
-	insertDoc := func(txn *badger.Txn, doc T) error {
-		// Check if the document already has an ID
-		if doc.GetID() == "" {
-			doc.SetID(primitive.NewObjectID().Hex())
-		}
+	if errors.Is(err, badger.ErrTxnTooBig) && c.tx == nil {
+		// Nothing was written, so the documents can still go in chunks
+		return c.insertManyChunked(docs, options)
+	}
+	if err != nil {
+		return result, err
+	}
 
-		// Generate a unique document ID
-		docID := doc.GetID()
-		key := fmt.Sprintf("%s|%s", c.Name, docID)
+	for _, doc := range docs {
+		result.InsertedIDs = append(result.InsertedIDs, doc.GetID())
+	}
+	return result, nil
+}
 
-		// Serialize the document
-		serializedDoc, err := bson.Marshal(doc)
-		if err != nil {
-			return err
-		}
+// insertManyChunked inserts docs in transactions of at most ChunkSize
+// documents. Documents that cannot be encoded or are duplicates are reported
+// and skipped; on any other error the chunks committed so far stay written
+// and are reported in InsertedIDs.
+func (c *Collection[T]) insertManyChunked(docs []T, options InsertManyOptions) (InsertManyResult, error) {
+	positions := make([]int, len(docs))
+	for i := range positions {
+		positions[i] = i
+	}
 
-		// Store the document in Badger
-		if err := txn.Set([]byte(key), serializedDoc); err != nil {
-			return err
+	var result InsertManyResult
+	var inserted []string // Of the running chunk, kept once it commits
+	var failed BulkWriteErrors
+	_, err := forEachItemChunk(c, positions, options.ChunkSize, func(progress Progress) {
+		result.InsertedIDs = append(result.InsertedIDs, inserted...)
+		result.Errors = append(result.Errors, failed...)
+		if options.Progress != nil {
+			options.Progress(progress)
 		}
-
-		// Get the indexable fields
-		indexableFields := getIndexableFields(doc, c.Indexes)
-
-		// Update indexes for the indexable fields
-		for field, value := range indexableFields {
-			indexKey := fmt.Sprintf("%s|%s|%v|%s", c.Name, field, value, docID)
-			if err := txn.Set([]byte(indexKey), []byte(docID)); err != nil {
-				return err
+	}, func(txn *badger.Txn, positions []int) (int, error) {
+		inserted, failed = nil, nil
+		for _, i := range positions {
+			entries, err := insertEntries(c, docs[i])
+			if err != nil {
+				failed = append(failed, BulkWriteError{Index: i, Err: err})
+				continue
 			}
-		}
-
-		return nil
-	}
-
-	// Perform the batch insert operation in a single transaction
-	err := c.Db.Update(func(txn *badger.Txn) error {
-		for _, doc := range docs {
-			if err := insertDoc(txn, doc); err != nil {
-				return err
+			err = nativeWriteInsert(c, txn, docs[i].GetID(), entries)
+			if errors.Is(err, ErrDuplicateID) {
+				failed = append(failed, BulkWriteError{Index: i, Err: err})
+				continue
 			}
+			if err != nil {
+				return 0, err
+			}
+			inserted = append(inserted, docs[i].GetID())
 		}
-
-		return nil
+		return len(inserted), nil
 	})
+	if err != nil {
+		return result, err
+	}
 
-	return err
+	if len(result.Errors) > 0 {
+		return result, result.Errors
+	}
+	return result, nil
 }
@@ -0,0 +1,124 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+func TestInsertDuplicateID(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products", core.CollectionOptions{Versioning: true})
+	if err := products.Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := products.UpdateByID("p1", core.Update{"$set": map[string]interface{}{"price": 12.0}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := products.Insert(&product{ID: "p1", Name: "chair"}); !errors.Is(err, core.ErrDuplicateID) {
+		t.Errorf("Insert error = %v, want ErrDuplicateID", err)
+	}
+	for _, atomicity := range []core.Atomicity{core.AllOrNothing, core.Chunked} {
+		docs := []*product{{ID: "p2", Name: "desk"}, {ID: "p1", Name: "chair"}}
+		result, err := products.InsertMany(docs, core.InsertManyOptions{Atomicity: atomicity})
+		if !errors.Is(err, core.ErrDuplicateID) {
+			t.Errorf("InsertMany(%v) error = %v, want ErrDuplicateID", atomicity, err)
+		}
+		if len(result.Errors) != 1 || result.Errors[0].Index != 1 {
+			t.Errorf("InsertMany(%v) errors = %v, want one for index 1", atomicity, result.Errors)
+		}
+	}
+
+	doc, err := products.FindByID("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["name"] != "lamp" || doc["_v"] != int64(2) {
+		t.Errorf("stored document = %v, want the updated lamp at version 2", doc)
+	}
+	if _, err := products.FindByID("p2"); err != nil {
+		t.Errorf("document inserted in Chunked mode beside the duplicate: %v", err)
+	}
+}
+
+func TestInsertOverwritesWithoutVersioning(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products")
+	if err := products.Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := products.Insert(&product{ID: "p1", Name: "chair"}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	doc, err := products.FindByID("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["name"] != "chair" {
+		t.Errorf("stored document = %v, want the chair", doc)
+	}
+}
+
+func TestInsertManyChunkedPrunesVersions(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products", core.CollectionOptions{KeepVersions: 1})
+	if err := products.Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := products.DeleteByID("p1"); err != nil {
+		t.Fatal(err)
+	}
+
+	chunked := core.InsertManyOptions{Atomicity: core.Chunked}
+	if _, err := products.InsertMany([]*product{{ID: "p1", Name: "desk lamp"}}, chunked); err != nil {
+		t.Fatalf("InsertMany: %v", err)
+	}
+
+	versions, err := products.History("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("%d versions kept, want 2: %v", len(versions), versions)
+	}
+	if versions[1].Change != core.Inserted || versions[1].Doc["name"] != "desk lamp" {
+		t.Errorf("latest version = %+v, want the chunked insert", versions[1])
+	}
+}
+
+func TestInsertManyBeyondOneTransaction(t *testing.T) {
+	// 2000 documents of 10KB are too many for one Badger transaction
+	name := strings.Repeat("x", 10<<10)
+	newDocs := func() []*product {
+		docs := make([]*product, 2000)
+		for i := range docs {
+			docs[i] = &product{ID: fmt.Sprintf("p%04d", i), Name: name}
+		}
+		return docs
+	}
+
+	for _, options := range []core.InsertManyOptions{
+		{},
+		{Atomicity: core.Chunked, ChunkSize: 2000},
+	} {
+		products := core.NewCollection[*product](testutil.OpenDB(t), "products")
+		result, err := products.InsertMany(newDocs(), options)
+		if err != nil {
+			t.Fatalf("InsertMany(%+v): %v", options, err)
+		}
+		if len(result.InsertedIDs) != 2000 {
+			t.Errorf("InsertMany(%+v) reported %d inserted, want 2000", options, len(result.InsertedIDs))
+		}
+
+		stored, err := products.Find(core.Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored) != 2000 {
+			t.Errorf("InsertMany(%+v) stored %d documents, want 2000", options, len(stored))
+		}
+	}
+}
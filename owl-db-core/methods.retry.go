@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// RetryOptions configures how read-write transactions that fail with
+// badger.ErrConflict are retried.
+type RetryOptions struct {
+	MaxRetries int           // Retries after the first attempt; 0 disables retrying
+	BaseDelay  time.Duration // Backoff before the first retry, doubled for each retry
+	MaxDelay   time.Duration // Upper bound for the backoff
+}
+
+// DefaultRetryOptions is used when no RetryOptions are given.
+var DefaultRetryOptions = RetryOptions{
+	MaxRetries: 5,
+	BaseDelay:  5 * time.Millisecond,
+	MaxDelay:   250 * time.Millisecond,
+}
+
+// Transaction metrics, published with expvar next to Badger's own metrics
+var (
+	metricConflicts        = expvar.NewInt("owl_db_txn_conflicts_total")
+	metricRetries          = expvar.NewInt("owl_db_txn_retries_total")
+	metricRetriesExhausted = expvar.NewInt("owl_db_txn_retries_exhausted_total")
+)
+
+// RetryExhaustedError is returned when a transaction still conflicts after
+// every retry. It unwraps to badger.ErrConflict.
+type RetryExhaustedError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("transaction failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// withRetry runs attempt until it succeeds, fails with an error other than
+// badger.ErrConflict, or the retries are exhausted.
+func withRetry(ctx context.Context, options RetryOptions, attempt func() error) error {
+	delay := max(options.BaseDelay, 0)
+
+	for attempts := 1; ; attempts++ {
+		err := attempt()
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+		metricConflicts.Add(1)
+
+		if attempts > options.MaxRetries {
+			metricRetriesExhausted.Add(1)
+			return &RetryExhaustedError{Attempts: attempts, Err: err}
+		}
+		metricRetries.Add(1)
+
+		// Sleep for a random duration in [delay/2, delay] so competing writers spread out
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		// Double the backoff, without overflowing when MaxDelay is unbounded
+		if delay < math.MaxInt64/2 {
+			delay *= 2
+		}
+		if options.MaxDelay > 0 && delay > options.MaxDelay {
+			delay = options.MaxDelay
+		}
+	}
+}
+
+// retryOptionsOrDefault returns the given options with negative values
+// raised to 0, or DefaultRetryOptions if there are none.
+func retryOptionsOrDefault(options *RetryOptions) RetryOptions {
+	if options == nil {
+		return DefaultRetryOptions
+	}
+	return RetryOptions{
+		MaxRetries: max(options.MaxRetries, 0),
+		BaseDelay:  max(options.BaseDelay, 0),
+		MaxDelay:   max(options.MaxDelay, 0),
+	}
+}
@@ -0,0 +1,42 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+func TestRetryWithNegativeDelay(t *testing.T) {
+	db := testutil.OpenDB(t)
+	products := core.NewCollection[*product](db, "products")
+	if err := products.Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+
+	retry := &core.RetryOptions{MaxRetries: 2, BaseDelay: -time.Second, MaxDelay: -time.Second}
+	update := core.Update{"$inc": map[string]interface{}{"qty": 1}}
+	attempts := 0
+	err := db.WithTransaction(context.Background(), func(tx *core.Tx) error {
+		attempts++
+		if _, err := products.WithTx(tx).FindByID("p1"); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			// A write committed meanwhile makes the transaction conflict
+			if _, err := products.UpdateByID("p1", update); err != nil {
+				return err
+			}
+		}
+		_, err := products.WithTx(tx).UpdateByID("p1", update)
+		return err
+	}, core.TransactionOptions{Retry: retry})
+	if err != nil {
+		t.Fatalf("WithTransaction: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("%d attempts, want 2", attempts)
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"errors"
+	"sync"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+var (
+	ErrSnapshotClosed   = errors.New("snapshot has been closed")
+	ErrSnapshotReadOnly = errors.New("cannot write through a snapshot")
+)
+
+// Snapshot is a read-only view of the database as it was when the snapshot
+// was taken. Bind collections to it with WithSnapshot; their reads all see
+// the same version of the data however many writes happen meanwhile, which
+// keeps multi-query reports consistent:
+//
+//	snap := db.Snapshot()
+//	defer snap.Close()
+//	paid, err := orders.WithSnapshot(snap).Find(core.Filter{"status": "paid"})
+//	...
+//	totals, err := products.WithSnapshot(snap).Aggregate(pipeline)
+//
+// A snapshot keeps Badger from discarding the versions it reads, so close it
+// as soon as the report is done. It is safe for concurrent use.
+type Snapshot struct {
+	db     *badger.DB
+	txn    *badger.Txn
+	mu     sync.RWMutex // Held for reading by reads and for writing by Close
+	closed bool
+}
+
+// Snapshot pins a read timestamp and returns a view of the data as of that
+// timestamp.
+func (db *DB) Snapshot() *Snapshot {
+	return &Snapshot{db: db.Badger, txn: db.Badger.NewTransaction(false)}
+}
+
+// ReadTs returns the version the snapshot reads at, in the same numbering as
+// the versions returned by Backup.
+func (s *Snapshot) ReadTs() uint64 {
+	return s.txn.ReadTs()
+}
+
+// Close releases the snapshot. Collections bound to it fail with
+// ErrSnapshotClosed afterwards. Closing twice is harmless.
+func (s *Snapshot) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		s.txn.Discard()
+	}
+}
+
+// WithSnapshot returns a copy of the collection that reads from snap instead
+// of the latest data. Its write methods fail with ErrSnapshotReadOnly.
+func (c *Collection[T]) WithSnapshot(snap *Snapshot) *Collection[T] {
+	bound := *c
+	bound.tx = nil
+	bound.snapshot = snap
+	return &bound
+}
+
+func (s *Snapshot) run(db *badger.DB, fn func(txn *badger.Txn) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrSnapshotClosed
+	}
+	if db != s.db {
+		return errors.New("snapshot belongs to a different database")
+	}
+	return fn(s.txn)
+}
@@ -0,0 +1,60 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+func TestSnapshotIgnoresLaterWrites(t *testing.T) {
+	db := testutil.OpenDB(t)
+	products := core.NewCollection[*product](db, "products")
+	testutil.Seed(t, products, "testdata/products.json")
+
+	snap := db.Snapshot()
+	defer snap.Close()
+	pinned := products.WithSnapshot(snap)
+
+	if err := products.Insert(&product{ID: "p4", Name: "shelf"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := products.UpdateByID("p1", core.Update{"$set": map[string]interface{}{"price": 15.0}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := products.DeleteByID("p2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := storedIDs(t, pinned); got != "[p1 p2 p3]" {
+		t.Errorf("snapshot finds %s, want [p1 p2 p3]", got)
+	}
+	if doc, err := pinned.FindByID("p1"); err != nil || doc["price"] != 12.5 {
+		t.Errorf("snapshot FindByID(p1) = %v, %v, want the price before the update", doc, err)
+	}
+	if doc, err := pinned.FindOne(core.Filter{"name": "desk"}); err != nil || doc == nil {
+		t.Errorf("snapshot FindOne(desk) = %v, %v, want the deleted desk", doc, err)
+	}
+	counted, err := pinned.Aggregate(core.Pipeline{{"$count": "n"}})
+	if err != nil {
+		t.Fatalf("snapshot Aggregate: %v", err)
+	}
+	if fmt.Sprint(counted) != "[map[n:3]]" {
+		t.Errorf("snapshot Aggregate count = %v, want 3", counted)
+	}
+
+	// The collection itself sees the writes
+	if got := storedIDs(t, products); got != "[p1 p3 p4]" {
+		t.Errorf("collection finds %s, want [p1 p3 p4]", got)
+	}
+
+	if err := pinned.Insert(&product{ID: "p5"}); !errors.Is(err, core.ErrSnapshotReadOnly) {
+		t.Errorf("Insert through a snapshot = %v, want ErrSnapshotReadOnly", err)
+	}
+	snap.Close()
+	if _, err := pinned.Find(core.Filter{}); !errors.Is(err, core.ErrSnapshotClosed) {
+		t.Errorf("Find after Close = %v, want ErrSnapshotClosed", err)
+	}
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+var ErrTxDone = errors.New("transaction has already been committed or discarded")
+
+// Tx is a read-write transaction shared by several collections. Bind a
+// collection to it with WithTx; everything done through the bound
+// collections commits or aborts together.
+//
+//	err := db.WithTransaction(ctx, func(tx *core.Tx) error {
+//		if err := orders.WithTx(tx).Insert(order); err != nil {
+//			return err
+//		}
+//		_, err := products.WithTx(tx).UpdateByID(productID, core.Update{
+//			"$inc": map[string]interface{}{"stock": -1},
+//		})
+//		return err
+//	})
+type Tx struct {
+	ctx  context.Context
+	db   *badger.DB
+	txn  *badger.Txn
+	done bool
+}
+
+type TransactionOptions struct {
+	Retry *RetryOptions // Retry policy on conflicts, DefaultRetryOptions if nil
+}
+
+// WithTransaction runs fn in a single Badger transaction and commits it if fn
+// returns nil. If fn returns an error or ctx is cancelled the transaction is
+// discarded. An operation that fails inside fn may have written part of its
+// changes, so return its error to abort rather than ignoring it.
+//
+// When the commit conflicts with another transaction, fn is run again in a
+// fresh transaction, so it must not have side effects outside of tx.
+func (db *DB) WithTransaction(ctx context.Context, fn func(tx *Tx) error, opts ...TransactionOptions) error {
+	var options TransactionOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	return withRetry(ctx, retryOptionsOrDefault(options.Retry), func() error {
+		return runTransaction(ctx, db.Badger, fn)
+	})
+}
+
+func runTransaction(ctx context.Context, db *badger.DB, fn func(tx *Tx) error) error {
+	txn := db.NewTransaction(true)
+	tx := &Tx{ctx: ctx, db: db, txn: txn}
+	defer func() {
+		tx.done = true
+		txn.Discard()
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx.done = true
+	return txn.Commit()
+}
+
+// WithTx returns a copy of the collection whose methods run inside tx instead
+// of opening their own transactions.
+func (c *Collection[T]) WithTx(tx *Tx) *Collection[T] {
+	bound := *c
+	bound.tx = tx
+	bound.snapshot = nil
+	return &bound
+}
+
+func (tx *Tx) run(db *badger.DB, fn func(txn *badger.Txn) error) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if db != tx.db {
+		return errors.New("transaction belongs to a different database")
+	}
+	if err := tx.ctx.Err(); err != nil {
+		return err
+	}
+	return fn(tx.txn)
+}
+
+// update runs fn in the collection's transaction if it is bound to one, or in
+// a new read-write transaction that is retried on conflicts. fn may therefore
+// run more than once and must reset any state it accumulates.
+func (c *Collection[T]) update(fn func(txn *badger.Txn) error) error {
+	if err := c.writable(); err != nil {
+		return err
+	}
+	if c.tx != nil {
+		// Conflicts surface when the whole transaction commits
+		return c.tx.run(c.Db, fn)
+	}
+	return withRetry(context.Background(), c.Retry, func() error {
+		return c.Db.Update(fn)
+	})
+}
+
+// view runs fn in the collection's transaction or snapshot if it is bound to
+// one, or in a new read-only transaction.
+func (c *Collection[T]) view(fn func(txn *badger.Txn) error) error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.tx != nil {
+		return c.tx.run(c.Db, fn)
+	}
+	if c.snapshot != nil {
+		return c.snapshot.run(c.Db, fn)
+	}
+	return c.Db.View(fn)
+}
+
+// writable reports why the collection can't be written, if it can't.
+func (c *Collection[T]) writable() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.snapshot != nil {
+		return ErrSnapshotReadOnly
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+func TestWithTransactionAbortsEveryCollection(t *testing.T) {
+	db := testutil.OpenDB(t)
+	products := core.NewCollection[*product](db, "products")
+	orders := core.NewCollection[*order](db, "orders")
+	testutil.Seed(t, products, "testdata/products.json")
+
+	failure := errors.New("payment declined")
+	err := db.WithTransaction(context.Background(), func(tx *core.Tx) error {
+		if err := orders.WithTx(tx).Insert(&order{ID: "o1", ProductID: "p1"}); err != nil {
+			return err
+		}
+		if _, err := products.WithTx(tx).UpdateByID("p1", core.Update{"$inc": map[string]interface{}{"qty": -1}}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithTransaction error = %v, want the error of fn", err)
+	}
+
+	if _, err := orders.FindByID("o1"); err == nil {
+		t.Error("order inserted by an aborted transaction")
+	}
+	doc, err := products.FindByID("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["qty"] != int32(4) {
+		t.Errorf("qty = %v, want the seeded 4", doc["qty"])
+	}
+}
+
+func TestWithTransactionRetriesConcurrentWriters(t *testing.T) {
+	db := testutil.OpenDB(t)
+	products := core.NewCollection[*product](db, "products")
+	testutil.Seed(t, products, "testdata/products.json")
+
+	// Every writer reads then increments the same document, so they conflict
+	const writers = 8
+	retry := &core.RetryOptions{MaxRetries: 100}
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.WithTransaction(context.Background(), func(tx *core.Tx) error {
+				if _, err := products.WithTx(tx).FindByID("p2"); err != nil {
+					return err
+				}
+				_, err := products.WithTx(tx).UpdateByID("p2", core.Update{"$inc": map[string]interface{}{"qty": 1}})
+				return err
+			}, core.TransactionOptions{Retry: retry})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("WithTransaction: %v", err)
+		}
+	}
+
+	doc, err := products.FindByID("p2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["qty"] != int32(1+writers) {
+		t.Errorf("qty = %v, want %d", doc["qty"], 1+writers)
+	}
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+var ErrPathNotFound = errors.New("no path between the documents")
+
+// Direction selects which edges a traversal follows.
+type Direction int
+
+const (
+	Outbound     Direction = iota // Follow edges from the node to its targets
+	Inbound                       // Follow edges from the node back to their sources
+	AnyDirection                  // Follow edges both ways
+)
+
+type TraversalOptions struct {
+	Labels     []string  // Edge labels to follow, every declared label if empty
+	Direction  Direction // Outbound by default
+	MaxDepth   int       // Maximum number of hops from the start, unlimited if 0
+	NodeFilter Filter    // Nodes that don't match are neither returned nor expanded
+	EdgeFilter Filter    // Only edges whose properties match are followed
+}
+
+// VisitedNode is a document reached by a traversal and its distance in hops
+// from the start.
+type VisitedNode struct {
+	Doc   map[string]interface{}
+	Depth int
+}
+
+// Traverse walks the graph breadth-first from startID and returns every node
+// reached in order of depth, starting with the start node at depth 0. Each
+// node is visited once, at its smallest depth.
+func (c *Collection[T]) Traverse(startID string, opts ...TraversalOptions) ([]VisitedNode, error) {
+	var options TraversalOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if err := c.checkEdgeLabels(options.Labels); err != nil {
+		return nil, err
+	}
+
+	var visited []VisitedNode
+	err := c.view(func(txn *badger.Txn) error {
+		visited = nil
+
+		start, found, err := nativeGet(c, txn, startID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("document %s not found", startID)
+		}
+		visited = append(visited, VisitedNode{Doc: start, Depth: 0})
+
+		seen := map[string]bool{startID: true}
+		frontier := []string{startID}
+		for depth := 1; len(frontier) > 0 && (options.MaxDepth == 0 || depth <= options.MaxDepth); depth++ {
+			var next []string
+			for _, id := range frontier {
+				neighbors, err := nativeNeighbors(c, txn, id, options.Labels, options.Direction, options.EdgeFilter)
+				if err != nil {
+					return err
+				}
+
+				for _, neighborID := range neighbors {
+					if seen[neighborID] {
+						continue
+					}
+					seen[neighborID] = true
+
+					doc, found, err := nativeGet(c, txn, neighborID)
+					if err != nil {
+						return err
+					}
+					if !found || !matchDocument(doc, options.NodeFilter) {
+						continue
+					}
+					visited = append(visited, VisitedNode{Doc: doc, Depth: depth})
+					next = append(next, neighborID)
+				}
+			}
+			frontier = next
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return visited, nil
+}
+
+// ShortestPath returns the IDs of the nodes on a shortest directed path from
+// fromID to toID, both included, following only the given labels (every
+// label if none). It runs a bidirectional breadth-first search within a
+// single read transaction and returns ErrPathNotFound if toID is unreachable.
+func (c *Collection[T]) ShortestPath(fromID, toID string, labels []string) ([]string, error) {
+	if err := c.checkEdgeLabels(labels); err != nil {
+		return nil, err
+	}
+
+	var path []string
+	err := c.view(func(txn *badger.Txn) error {
+		path = nil
+
+		for _, docID := range []string{fromID, toID} {
+			_, found, err := nativeGet(c, txn, docID)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("document %s not found", docID)
+			}
+		}
+		if fromID == toID {
+			path = []string{fromID}
+			return nil
+		}
+
+		// Each side maps the nodes it reached to the node it came from, and
+		// to their distance from its end
+		forward := newSearchSide(fromID, func(id string) ([]string, error) {
+			return nativeNeighbors(c, txn, id, labels, Outbound, nil)
+		})
+		backward := newSearchSide(toID, func(id string) ([]string, error) {
+			return nativeNeighbors(c, txn, id, labels, Inbound, nil)
+		})
+
+		for len(forward.frontier) > 0 && len(backward.frontier) > 0 {
+			// Expand the smaller frontier by one full level
+			side, other := forward, backward
+			if len(backward.frontier) < len(forward.frontier) {
+				side, other = backward, forward
+			}
+
+			meeting, err := side.expand(other)
+			if err != nil {
+				return err
+			}
+			if meeting != "" {
+				path = append(forward.pathTo(meeting), backward.pathFrom(meeting)...)
+				return nil
+			}
+		}
+		return ErrPathNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return path, nil
+}
+
+// searchSide is one half of a bidirectional breadth-first search.
+type searchSide struct {
+	parent    map[string]string
+	depth     map[string]int
+	frontier  []string
+	neighbors func(id string) ([]string, error)
+}
+
+func newSearchSide(startID string, neighbors func(id string) ([]string, error)) *searchSide {
+	return &searchSide{
+		parent:    map[string]string{startID: ""},
+		depth:     map[string]int{startID: 0},
+		frontier:  []string{startID},
+		neighbors: neighbors,
+	}
+}
+
+// expand visits the next level of this side and returns the node where it
+// meets the other side on the shortest combined path, or "" if they don't
+// meet yet.
+func (s *searchSide) expand(other *searchSide) (string, error) {
+	var next []string
+	meeting, best := "", 0
+
+	for _, id := range s.frontier {
+		neighbors, err := s.neighbors(id)
+		if err != nil {
+			return "", err
+		}
+
+		for _, neighborID := range neighbors {
+			if _, seen := s.parent[neighborID]; seen {
+				continue
+			}
+			s.parent[neighborID] = id
+			s.depth[neighborID] = s.depth[id] + 1
+			next = append(next, neighborID)
+
+			// The other side's nodes differ in depth, so keep the meeting
+			// point with the shortest total length
+			if otherDepth, ok := other.depth[neighborID]; ok {
+				if length := s.depth[neighborID] + otherDepth; meeting == "" || length < best {
+					meeting, best = neighborID, length
+				}
+			}
+		}
+	}
+
+	s.frontier = next
+	return meeting, nil
+}
+
+// pathTo returns the path from this side's end to id, both included.
+func (s *searchSide) pathTo(id string) []string {
+	var path []string
+	for ; id != ""; id = s.parent[id] {
+		path = append([]string{id}, path...)
+	}
+	return path
+}
+
+// pathFrom returns the path from the node after id to this side's end.
+func (s *searchSide) pathFrom(id string) []string {
+	var path []string
+	for id = s.parent[id]; id != ""; id = s.parent[id] {
+		path = append(path, id)
+	}
+	return path
+}
+
+// checkEdgeLabels validates the labels a traversal may follow.
+func (c *Collection[T]) checkEdgeLabels(labels []string) error {
+	for _, label := range labels {
+		if err := c.checkEdgeLabel(label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
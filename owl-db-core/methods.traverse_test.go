@@ -0,0 +1,112 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+// formatVisited lists visited nodes as id:depth, sorted by depth then ID.
+func formatVisited(visited []core.VisitedNode) string {
+	sort.SliceStable(visited, func(i, j int) bool {
+		if visited[i].Depth != visited[j].Depth {
+			return visited[i].Depth < visited[j].Depth
+		}
+		return visited[i].Doc["_id"].(string) < visited[j].Doc["_id"].(string)
+	})
+
+	var formatted []string
+	for _, node := range visited {
+		formatted = append(formatted, fmt.Sprintf("%s:%d", node.Doc["_id"], node.Depth))
+	}
+	return strings.Join(formatted, " ")
+}
+
+// openRoutes opens the graph
+//
+//	f -road-> a -road-> b -road-> c -road-> d    e
+//	          a -rail-----------> c
+func openRoutes(t *testing.T) *core.Collection[*product] {
+	return openGraph(t, testutil.OpenDB(t), "a b c d e f", []graphEdge{
+		{"f", "road", "a", nil},
+		{"a", "road", "b", nil},
+		{"b", "road", "c", nil},
+		{"c", "road", "d", nil},
+		{"a", "rail", "c", nil},
+	})
+}
+
+func TestTraverse(t *testing.T) {
+	nodes := openRoutes(t)
+
+	for _, test := range []struct {
+		name    string
+		options core.TraversalOptions
+		want    string
+	}{
+		{"outbound", core.TraversalOptions{}, "a:0 b:1 c:1 d:2"},
+		{"max depth", core.TraversalOptions{MaxDepth: 1}, "a:0 b:1 c:1"},
+		{"labels", core.TraversalOptions{Labels: []string{"road"}}, "a:0 b:1 c:2 d:3"},
+		{"inbound", core.TraversalOptions{Direction: core.Inbound}, "a:0 f:1"},
+		{"any direction", core.TraversalOptions{Direction: core.AnyDirection, MaxDepth: 1}, "a:0 b:1 c:1 f:1"},
+		{
+			"node filter",
+			core.TraversalOptions{Labels: []string{"road"}, NodeFilter: core.Filter{"name": core.Filter{"$ne": "c"}}},
+			"a:0 b:1",
+		},
+	} {
+		visited, err := nodes.Traverse("a", test.options)
+		if err != nil {
+			t.Fatalf("%s: Traverse: %v", test.name, err)
+		}
+		if got := formatVisited(visited); got != test.want {
+			t.Errorf("%s: visited %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	if _, err := nodes.Traverse("z"); err == nil {
+		t.Error("Traverse from a missing document succeeded")
+	}
+	if _, err := nodes.Traverse("a", core.TraversalOptions{Labels: []string{"air"}}); err == nil {
+		t.Error("Traverse with an undeclared label succeeded")
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	nodes := openRoutes(t)
+
+	for _, test := range []struct {
+		from, to string
+		labels   []string
+		want     string
+	}{
+		{"a", "d", nil, "[a c d]"},
+		{"a", "d", []string{"road"}, "[a b c d]"},
+		{"f", "d", nil, "[f a c d]"},
+		{"a", "a", nil, "[a]"},
+	} {
+		path, err := nodes.ShortestPath(test.from, test.to, test.labels)
+		if err != nil {
+			t.Fatalf("ShortestPath(%s, %s, %v): %v", test.from, test.to, test.labels, err)
+		}
+		if got := fmt.Sprint(path); got != test.want {
+			t.Errorf("ShortestPath(%s, %s, %v) = %s, want %s", test.from, test.to, test.labels, got, test.want)
+		}
+	}
+
+	// Edges are only followed forwards
+	if _, err := nodes.ShortestPath("d", "a", nil); !errors.Is(err, core.ErrPathNotFound) {
+		t.Errorf("ShortestPath against the edges = %v, want ErrPathNotFound", err)
+	}
+	if _, err := nodes.ShortestPath("a", "e", nil); !errors.Is(err, core.ErrPathNotFound) {
+		t.Errorf("ShortestPath to an isolated node = %v, want ErrPathNotFound", err)
+	}
+	if _, err := nodes.ShortestPath("a", "z", nil); err == nil {
+		t.Error("ShortestPath to a missing document succeeded")
+	}
+}
@@ -3,158 +3,185 @@ package core
 import (
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"sort"
 	"strings"
-	"sync"
+	"time"
 
 	badger "github.com/dgraph-io/badger/v4"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Update map[string]interface{}
 
-func applyUpdate(doc map[string]interface{}, update Update) error {
-	for op, fields := range update {
-		switch op {
-		case "$set":
-			setFields, ok := fields.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("invalid $set operation")
-			}
-			// Apply $set operation
-			for key, value := range setFields {
-				updateNestedField(doc, key, value)
-			}
+// UpdateSpec is accepted by the update methods: either an Update of operators
+// or a Pipeline of aggregation stages.
+type UpdateSpec interface {
+	compileUpdate() (compiledUpdate, error)
+}
 
-		case "$inc":
-			incFields, ok := fields.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("invalid $inc operation")
-			}
-			// Apply $inc operation
-			for key, value := range incFields {
-				if err := incrementField(doc, key, value); err != nil {
-					return err
-				}
-			}
+// compiledUpdate is a validated update that can be applied to a document.
+type compiledUpdate interface {
+	apply(doc map[string]interface{}, ctx updateContext) error
+}
 
-		case "$unset":
-			unsetFields, ok := fields.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("invalid $unset operation")
-			}
-			// Apply $unset operation
-			for key := range unsetFields {
-				deleteNestedField(doc, key)
-			}
+// replacement is an UpdateSpec that swaps in a whole new document.
+type replacement struct {
+	doc           map[string]interface{}
+	keepCreatedAt bool
+}
 
-		case "$rename":
-			renameFields, ok := fields.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("invalid $rename operation")
-			}
-			// Apply $rename operation
-			for oldKey, newKey := range renameFields {
-				if newKeyStr, ok := newKey.(string); ok {
-					err := renameField(doc, oldKey, newKeyStr)
-					if err != nil {
-						return err
-					}
-				} else {
-					return fmt.Errorf("new key in $rename must be a string")
-				}
-			}
+func (r replacement) compileUpdate() (compiledUpdate, error) {
+	return r, nil
+}
 
-		case "$push":
-			pushFields, ok := fields.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("invalid $push operation")
-			}
-			// Apply $push operation
-			for key, value := range pushFields {
-				err := pushToArray(doc, key, value)
-				if err != nil {
-					return err
-				}
-			}
+func (r replacement) apply(doc map[string]interface{}, ctx updateContext) error {
+	docID, hasID := doc["_id"]
+	createdAt, hasCreatedAt := doc["createdAt"]
 
-		case "$pull":
-			pullFields, ok := fields.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("invalid $pull operation")
-			}
-			// Apply $pull operation
-			for key, value := range pullFields {
-				err := pullFromArray(doc, key, value)
-				if err != nil {
-					return err
-				}
-			}
+	for key := range doc {
+		delete(doc, key)
+	}
+	for key, value := range r.doc {
+		doc[key] = value
+	}
 
-		case "$pullAll":
-			pullAllFields, ok := fields.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("invalid $pullAll operation")
-			}
-			// Apply $pullAll operation
-			for key, values := range pullAllFields {
-				if valueSlice, ok := values.([]interface{}); ok {
-					for _, value := range valueSlice {
-						err := pullFromArray(doc, key, value)
-						if err != nil {
-							return err
-						}
-					}
-				} else {
-					return fmt.Errorf("invalid value for $pullAll operation")
-				}
-			}
+	if hasID {
+		doc["_id"] = docID
+	}
+	if r.keepCreatedAt && hasCreatedAt {
+		doc["createdAt"] = createdAt
+	}
+	return nil
+}
 
-		case "$pop":
-			popFields, ok := fields.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("invalid $pop operation")
-			}
-			// Apply $pop operation
-			for key, value := range popFields {
-				if v, ok := value.(float64); ok { // Pop value should be 1 or -1
-					if v == 1 {
-						err := popFromArray(doc, key, true)
-						if err != nil {
-							return err
-						}
-					} else if v == -1 {
-						err := popFromArray(doc, key, false)
-						if err != nil {
-							return err
-						}
-					} else {
-						return fmt.Errorf("invalid value for $pop operation")
-					}
-				} else {
-					return fmt.Errorf("invalid value for $pop operation")
-				}
-			}
+func (u Update) compileUpdate() (compiledUpdate, error) {
+	operations, err := normalizeUpdate(u)
+	if err != nil {
+		return nil, err
+	}
+	return updateOperations(operations), nil
+}
 
-		case "$addToSet":
-			addToSetFields, ok := fields.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("invalid $addToSet operation")
-			}
-			// Apply $addToSet operation
-			for key, value := range addToSetFields {
-				err := addToSet(doc, key, value)
-				if err != nil {
-					return err
-				}
-			}
+// compileUpdateSpec validates an update before any transaction is opened.
+func compileUpdateSpec(update UpdateSpec) (compiledUpdate, error) {
+	if update == nil {
+		return nil, fmt.Errorf("update document must not be nil")
+	}
+	return update.compileUpdate()
+}
 
-		default:
-			return fmt.Errorf("unsupported update operator: %s", op)
+type UpdateOptions struct {
+	Upsert          bool     // Insert a new document when nothing matches the filter
+	ArrayFilters    []Filter // Conditions for $[<identifier>] paths, e.g. {"item.productID": "1"}
+	DryRun          bool     // Return the resulting documents without writing them
+	ExpectedVersion int64    // Fail with ErrVersionMismatch unless the stored _v matches (0 skips the check)
+
+	// UpdateMany only
+	Atomicity Atomicity      // AllOrNothing (default) or Chunked
+	ChunkSize int            // Documents per transaction when Chunked, 1000 if zero
+	Progress  func(Progress) // Called after each committed chunk
+}
+
+type UpdateResult struct {
+	MatchedCount int                      // Number of documents matched by the filter
+	UpsertedID   string                   // ID of the document created by an upsert
+	Version      int64                    // Resulting _v of the last written document in a versioned collection
+	Documents    []map[string]interface{} // Resulting documents, only filled for DryRun
+}
+
+// updateOperations is the compiled form of an Update.
+type updateOperations []updateOperation
+
+// apply applies normalized update operations to doc in place. Paths may
+// use array indexes and the positional $, $[] and $[<identifier>] operators,
+// which are resolved against the document through ctx.
+func (operations updateOperations) apply(doc map[string]interface{}, ctx updateContext) error {
+	for _, operation := range operations {
+		paths, err := ctx.expandPath(doc, operation.path)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if err := applyOperation(doc, operation.op, path, operation.value, ctx); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// applyOperation applies a single update operator to a resolved path.
+func applyOperation(doc map[string]interface{}, op string, path string, value interface{}, ctx updateContext) error {
+	switch op {
+	case "$set":
+		return updateNestedField(doc, path, value)
+
+	case "$inc":
+		return incrementField(doc, path, value)
+
+	case "$unset":
+		deleteNestedField(doc, path)
+		return nil
+
+	case "$rename":
+		newKey, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("new key in $rename must be a string")
+		}
+		return renameField(doc, path, newKey)
+
+	case "$push":
+		return pushToArray(doc, path, value)
+
+	case "$pull":
+		return pullFromArray(doc, path, value)
+
+	case "$pullAll":
+		valueSlice, ok := toArray(value)
+		if !ok {
+			return fmt.Errorf("invalid value for $pullAll operation")
+		}
+		return pullAllFromArray(doc, path, valueSlice)
+
+	case "$pop":
+		// Pop value should be 1 or -1
+		direction, ok := toDirection(value)
+		if !ok {
+			return fmt.Errorf("invalid value for $pop operation")
+		}
+		return popFromArray(doc, path, direction == 1)
+
+	case "$mul":
+		return multiplyField(doc, path, value)
+
+	case "$min", "$max":
+		return boundField(doc, path, value, op == "$min")
+
+	case "$currentDate":
+		now, err := currentDateValue(value)
+		if err != nil {
+			return err
+		}
+		return updateNestedField(doc, path, now)
+
+	case "$setOnInsert":
+		// $setOnInsert only takes effect when an upsert creates the document
+		if !ctx.inserting {
+			return nil
+		}
+		return updateNestedField(doc, path, value)
+
+	case "$addToSet":
+		return addToSet(doc, path, value)
+
+	default:
+		return fmt.Errorf("unsupported update operator: %s", op)
+	}
+}
+
 // renameField renames a field in a nested document.
 func renameField(doc map[string]interface{}, oldKey string, newKey string) error {
 	oldValue, exists := getNestedValue(doc, strings.Split(oldKey, "."))
@@ -162,200 +189,459 @@ func renameField(doc map[string]interface{}, oldKey string, newKey string) error
 		return fmt.Errorf("field %s not found", oldKey)
 	}
 	deleteNestedField(doc, oldKey)
-	updateNestedField(doc, newKey, oldValue)
-	return nil
+	return updateNestedField(doc, newKey, oldValue)
 }
 
-// pushToArray adds a value to an array at the specified path.
+// pushToArray adds a value to an array at the specified path. The value may
+// be a modifier document using $each, $position, $slice and $sort.
 func pushToArray(doc map[string]interface{}, path string, value interface{}) error {
-	keys := strings.Split(path, ".")
-	lastKey := keys[len(keys)-1]
+	array, err := arrayField(doc, path)
+	if err != nil {
+		return err
+	}
+
+	modifiers, hasModifiers := value.(map[string]interface{})
+	if !hasModifiers || modifiers["$each"] == nil {
+		return updateNestedField(doc, path, append(array, value))
+	}
 
-	// Traverse to the nested map where the last key belongs
-	m := doc
-	for _, key := range keys[:len(keys)-1] {
-		if nested, ok := m[key].(map[string]interface{}); ok {
-			m = nested
-		} else {
-			// Create a new nested map if it doesn't exist
-			newMap := make(map[string]interface{})
-			m[key] = newMap
-			m = newMap
+	for modifier := range modifiers {
+		switch modifier {
+		case "$each", "$position", "$slice", "$sort":
+		default:
+			return fmt.Errorf("unsupported $push modifier: %s", modifier)
 		}
 	}
 
-	// Perform push operation
-	if array, ok := m[lastKey].([]interface{}); ok {
-		m[lastKey] = append(array, value)
-	} else {
-		m[lastKey] = []interface{}{value} // Create a new array if not exists
+	each, ok := toArray(modifiers["$each"])
+	if !ok {
+		return fmt.Errorf("$each in $push must be an array")
 	}
-	return nil
+
+	// Insert at $position (negative positions count from the end)
+	position := len(array)
+	if rawPosition, exists := modifiers["$position"]; exists {
+		p, _, kind, ok := toNumber(rawPosition)
+		if !ok || kind == numberFloat64 {
+			return fmt.Errorf("$position in $push must be an integer")
+		}
+		position = clampIndex(int(p), len(array))
+	}
+	result := make([]interface{}, 0, len(array)+len(each))
+	result = append(result, array[:position]...)
+	result = append(result, each...)
+	result = append(result, array[position:]...)
+
+	if rawSort, exists := modifiers["$sort"]; exists {
+		if err := sortArray(result, rawSort); err != nil {
+			return err
+		}
+	}
+
+	// Trim to $slice elements (negative keeps the last elements)
+	if rawSlice, exists := modifiers["$slice"]; exists {
+		n, _, kind, ok := toNumber(rawSlice)
+		if !ok || kind == numberFloat64 {
+			return fmt.Errorf("$slice in $push must be an integer")
+		}
+		if n >= 0 && int(n) < len(result) {
+			result = result[:n]
+		} else if n < 0 && int(-n) < len(result) {
+			result = result[len(result)+int(n):]
+		}
+	}
+
+	return updateNestedField(doc, path, result)
 }
 
-// pullFromArray removes a value from an array at the specified path.
-func pullFromArray(doc map[string]interface{}, path string, value interface{}) error {
-	keys := strings.Split(path, ".")
-	lastKey := keys[len(keys)-1]
+// pullFromArray removes every element of the array at the specified path that
+// matches the condition. A condition of operators (e.g. {"$gte": 6}) is applied
+// to each element, a plain document is matched against document elements and
+// any other value is compared for equality.
+func pullFromArray(doc map[string]interface{}, path string, condition interface{}) error {
+	array, exists := getNestedValue(doc, strings.Split(path, "."))
+	if !exists {
+		return nil // Field doesn't exist, nothing to pull
+	}
+	elements, ok := toArray(array)
+	if !ok {
+		return fmt.Errorf("cannot $pull from non-array field %s", path)
+	}
 
-	// Traverse to the nested map where the last key belongs
-	m := doc
-	for _, key := range keys[:len(keys)-1] {
-		if nested, ok := m[key].(map[string]interface{}); ok {
-			m = nested
-		} else {
-			// Field doesn't exist, nothing to pull
-			return nil
+	matches := func(element interface{}) bool {
+		return valuesEqual(element, condition)
+	}
+	if filter, ok := toFilter(condition); ok {
+		matches = func(element interface{}) bool {
+			return matchElement(element, filter)
 		}
 	}
 
-	// Perform pull operation
-	if array, ok := m[lastKey].([]interface{}); ok {
-		for i, v := range array {
-			if v == value { // Assuming equality check for simplicity
-				m[lastKey] = append(array[:i], array[i+1:]...) // Remove the item
-				break
-			}
+	result := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		if !matches(element) {
+			result = append(result, element)
 		}
 	}
-	return nil
+	return updateNestedField(doc, path, result)
+}
+
+// pullAllFromArray removes every element equal to one of values.
+func pullAllFromArray(doc map[string]interface{}, path string, values []interface{}) error {
+	array, exists := getNestedValue(doc, strings.Split(path, "."))
+	if !exists {
+		return nil // Field doesn't exist, nothing to pull
+	}
+	elements, ok := toArray(array)
+	if !ok {
+		return fmt.Errorf("cannot $pullAll from non-array field %s", path)
+	}
+
+	result := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		if !isIn(element, values) {
+			result = append(result, element)
+		}
+	}
+	return updateNestedField(doc, path, result)
 }
 
 // popFromArray removes the last or first element from an array at the specified path.
 func popFromArray(doc map[string]interface{}, path string, fromEnd bool) error {
-	keys := strings.Split(path, ".")
-	lastKey := keys[len(keys)-1]
+	value, exists := getNestedValue(doc, strings.Split(path, "."))
+	if !exists {
+		return nil // Nothing to pop
+	}
+	array, ok := toArray(value)
+	if !ok {
+		return fmt.Errorf("cannot $pop from non-array field %s", path)
+	}
 
-	// Traverse to the nested map where the last key belongs
-	m := doc
-	for _, key := range keys[:len(keys)-1] {
-		if nested, ok := m[key].(map[string]interface{}); ok {
-			m = nested
-		} else {
-			return fmt.Errorf("field %s not found", path)
+	// Perform pop operation
+	if len(array) == 0 {
+		return nil // Nothing to pop
+	}
+	if fromEnd {
+		return updateNestedField(doc, path, array[:len(array)-1]) // Remove the last item
+	}
+	return updateNestedField(doc, path, array[1:]) // Remove the first item
+}
+
+// addToSet adds a value to an array at the specified path if it does not
+// already exist. {"$each": [...]} adds each missing value.
+func addToSet(doc map[string]interface{}, path string, value interface{}) error {
+	array, err := arrayField(doc, path)
+	if err != nil {
+		return err
+	}
+
+	values := []interface{}{value}
+	if modifiers, ok := value.(map[string]interface{}); ok && modifiers["$each"] != nil {
+		each, ok := toArray(modifiers["$each"])
+		if !ok || len(modifiers) > 1 {
+			return fmt.Errorf("invalid $each in $addToSet")
 		}
+		values = each
 	}
 
-	// Perform pop operation
-	if array, ok := m[lastKey].([]interface{}); ok {
-		if len(array) == 0 {
-			return nil // Nothing to pop
+	for _, v := range values {
+		if !isIn(v, array) {
+			array = append(array, v)
 		}
-		if fromEnd {
-			m[lastKey] = array[:len(array)-1] // Remove the last item
-		} else {
-			m[lastKey] = array[1:] // Remove the first item
+	}
+	return updateNestedField(doc, path, array)
+}
+
+// arrayField returns a copy of the array at path, or an empty array if the field is missing.
+func arrayField(doc map[string]interface{}, path string) ([]interface{}, error) {
+	value, exists := getNestedValue(doc, strings.Split(path, "."))
+	if !exists || value == nil {
+		return []interface{}{}, nil
+	}
+	array, ok := toArray(value)
+	if !ok {
+		return nil, fmt.Errorf("field %s is not an array", path)
+	}
+	return append([]interface{}{}, array...), nil
+}
+
+// toArray converts the array types produced by BSON decoding and by callers into []interface{}.
+func toArray(value interface{}) ([]interface{}, bool) {
+	switch array := value.(type) {
+	case []interface{}:
+		return array, true
+	case primitive.A:
+		return array, true
+	}
+
+	// Typed slices such as []string
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	array := make([]interface{}, rv.Len())
+	for i := range array {
+		array[i] = rv.Index(i).Interface()
+	}
+	return array, true
+}
+
+// toFilter reports whether a $pull operand is a query condition rather than a plain value.
+func toFilter(condition interface{}) (Filter, bool) {
+	switch c := condition.(type) {
+	case Filter:
+		return c, true
+	case map[string]interface{}:
+		return Filter(c), true
+	case primitive.M:
+		return Filter(c), true
+	}
+	return nil, false
+}
+
+// isOperatorFilter reports whether every key of filter is an operator, as in
+// {"$gte": 6}, rather than a field.
+func isOperatorFilter(filter Filter) bool {
+	for key := range filter {
+		if !strings.HasPrefix(key, "$") {
+			return false
 		}
 	}
-	return nil
+	return true
 }
 
-// addToSet adds a value to an array at the specified path if it does not already exist.
-func addToSet(doc map[string]interface{}, path string, value interface{}) error {
-	keys := strings.Split(path, ".")
-	lastKey := keys[len(keys)-1]
-
-	// Traverse to the nested map where the last key belongs
-	m := doc
-	for _, key := range keys[:len(keys)-1] {
-		if nested, ok := m[key].(map[string]interface{}); ok {
-			m = nested
-		} else {
-			// Create a new nested map if it doesn't exist
-			newMap := make(map[string]interface{})
-			m[key] = newMap
-			m = newMap
-		}
-	}
-
-	// Perform addToSet operation
-	if array, ok := m[lastKey].([]interface{}); ok {
-		// Check if the value already exists
-		for _, v := range array {
-			if v == value {
-				return nil // Value already exists, do nothing
+// matchElement matches a single array element against a $pull condition.
+func matchElement(element interface{}, filter Filter) bool {
+	if !isOperatorFilter(filter) {
+		// Field conditions only apply to document elements
+		elementDoc, ok := element.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		// Operators given as plain maps, as in {"qty": {"$gte": 6}}, are
+		// applied like Filters rather than compared for equality
+		conditions := make(Filter, len(filter))
+		for field, condition := range filter {
+			if nested, ok := toFilter(condition); ok && len(nested) > 0 && isOperatorFilter(nested) {
+				condition = nested
 			}
+			conditions[field] = condition
 		}
-		m[lastKey] = append(array, value) // Add value to array
-	} else {
-		m[lastKey] = []interface{}{value} // Create new array if not exists
+		return matchDocument(elementDoc, conditions)
 	}
-	return nil
+
+	// Apply each operator to the element itself
+	wrapper := map[string]interface{}{"element": element}
+	for op, opVal := range filter {
+		if !applyOperator(wrapper, "element", Filter{op: opVal}) {
+			return false
+		}
+	}
+	return true
 }
 
-func updateNestedField(doc map[string]interface{}, path string, value interface{}) {
-	keys := strings.Split(path, ".")
-	lastKey := keys[len(keys)-1]
+// toDirection reads an operand that must be 1 or -1, such as the one of $pop.
+// Fractions like 1.5 are rejected rather than truncated.
+func toDirection(value interface{}) (int, bool) {
+	_, f, _, ok := toNumber(value)
+	if !ok || (f != 1 && f != -1) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// sortArray implements $sort for $push: 1/-1 orders the elements by value and
+// a document of field -> 1/-1 orders document elements by those fields.
+func sortArray(array []interface{}, spec interface{}) error {
+	if _, _, _, isNumber := toNumber(spec); isNumber {
+		direction, ok := toDirection(spec)
+		if !ok {
+			return fmt.Errorf("$sort in $push must be 1 or -1")
+		}
+		sort.SliceStable(array, func(i, j int) bool {
+			result, _ := compareValues(array[i], array[j])
+			return result*direction < 0
+		})
+		return nil
+	}
 
-	// Traverse to the nested map where the last key belongs
-	m := doc
-	for _, key := range keys[:len(keys)-1] {
-		if nested, ok := m[key].(map[string]interface{}); ok {
-			m = nested
-		} else {
-			// Create a new nested map if it doesn't exist
-			newMap := make(map[string]interface{})
-			m[key] = newMap
-			m = newMap
+	fields, ok := spec.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid $sort in $push")
+	}
+	sortFields := make([]SortField, 0, len(fields))
+	for field, rawDirection := range fields {
+		direction, ok := toDirection(rawDirection)
+		if !ok {
+			return fmt.Errorf("$sort in $push must be 1 or -1")
 		}
+		sortFields = append(sortFields, SortField{Field: field, Order: direction})
+	}
+	// Map iteration is random, so order the sort keys deterministically
+	sort.Slice(sortFields, func(i, j int) bool { return sortFields[i].Field < sortFields[j].Field })
+
+	sort.SliceStable(array, func(i, j int) bool {
+		docI, _ := array[i].(map[string]interface{})
+		docJ, _ := array[j].(map[string]interface{})
+		for _, sortField := range sortFields {
+			keys := strings.Split(sortField.Field, ".")
+			valI, _ := getNestedValue(docI, keys)
+			valJ, _ := getNestedValue(docJ, keys)
+			if result, _ := compareValues(valI, valJ); result != 0 {
+				return result*sortField.Order < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// clampIndex resolves a possibly negative array index into the range [0, length].
+func clampIndex(index int, length int) int {
+	if index < 0 {
+		index += length
 	}
-	// Set the value
-	m[lastKey] = value
+	return max(0, min(index, length))
 }
 
 func incrementField(doc map[string]interface{}, path string, increment interface{}) error {
-	keys := strings.Split(path, ".")
-	lastKey := keys[len(keys)-1]
+	// A missing field is set to the increment
+	currentValue, exists := getNestedValue(doc, strings.Split(path, "."))
+	if !exists {
+		if _, _, _, ok := toNumber(increment); !ok {
+			return fmt.Errorf("invalid increment value")
+		}
+		return updateNestedField(doc, path, increment)
+	}
+
+	result, err := addNumbers(currentValue, increment)
+	if err != nil {
+		return fmt.Errorf("cannot $inc field %s: %v", path, err)
+	}
+	return updateNestedField(doc, path, result)
+}
+
+// multiplyField multiplies a numeric field by factor, setting a missing field to zero.
+func multiplyField(doc map[string]interface{}, path string, factor interface{}) error {
+	currentValue, exists := getNestedValue(doc, strings.Split(path, "."))
+	if !exists {
+		// MongoDB sets a missing field to zero of the factor's type
+		currentValue = int32(0)
+	}
+
+	result, err := multiplyNumbers(currentValue, factor)
+	if err != nil {
+		return fmt.Errorf("cannot $mul field %s: %v", path, err)
+	}
+	return updateNestedField(doc, path, result)
+}
 
-	// Traverse to the nested map where the last key belongs
-	m := doc
-	for _, key := range keys[:len(keys)-1] {
-		if nested, ok := m[key].(map[string]interface{}); ok {
-			m = nested
-		} else {
-			return fmt.Errorf("field %s not found", path)
+// boundField implements $min (isMin is true) and $max: the field is only
+// replaced when value is lower (or higher) than the current value.
+func boundField(doc map[string]interface{}, path string, value interface{}, isMin bool) error {
+	currentValue, exists := getNestedValue(doc, strings.Split(path, "."))
+	if !exists {
+		return updateNestedField(doc, path, value)
+	}
+
+	result, comparable := compareValues(value, currentValue)
+	if !comparable {
+		return fmt.Errorf("cannot compare field %s of type %T with %T", path, currentValue, value)
+	}
+	if (isMin && result < 0) || (!isMin && result > 0) {
+		return updateNestedField(doc, path, value)
+	}
+	return nil
+}
+
+// currentDateValue returns the value stored by $currentDate. The operand is
+// either true (a date) or {"$type": "date" | "timestamp"}.
+func currentDateValue(spec interface{}) (interface{}, error) {
+	now := time.Now()
+
+	switch s := spec.(type) {
+	case bool:
+		if s {
+			return now, nil
+		}
+	case map[string]interface{}:
+		switch s["$type"] {
+		case "date":
+			return now, nil
+		case "timestamp":
+			return primitive.Timestamp{T: uint32(now.Unix())}, nil
 		}
 	}
+	return nil, fmt.Errorf("invalid value for $currentDate operation")
+}
 
-	// Perform increment operation
-	currentValue, ok := m[lastKey].(float64) // Assuming float64 for simplicity
+// addNumbers adds two numeric values, widening int32 -> int64 -> float64
+// the way MongoDB does for mixed operands.
+func addNumbers(a, b interface{}) (interface{}, error) {
+	ai, af, aKind, ok := toNumber(a)
 	if !ok {
-		return fmt.Errorf("field %s is not a number", path)
+		return nil, fmt.Errorf("value %v is not a number", a)
 	}
-	incValue, ok := increment.(float64)
+	bi, bf, bKind, ok := toNumber(b)
 	if !ok {
-		return fmt.Errorf("invalid increment value")
+		return nil, fmt.Errorf("value %v is not a number", b)
 	}
 
-	m[lastKey] = currentValue + incValue
-	return nil
+	switch max(aKind, bKind) {
+	case numberFloat64:
+		return af + bf, nil
+	case numberInt64:
+		sum := ai + bi
+		// Signed overflow happens when both operands share a sign the sum lacks
+		if (ai >= 0) == (bi >= 0) && (sum >= 0) != (ai >= 0) {
+			return nil, fmt.Errorf("integer overflow")
+		}
+		return sum, nil
+	default:
+		return narrowInt(ai + bi), nil
+	}
 }
 
-func deleteNestedField(doc map[string]interface{}, path string) {
-	keys := strings.Split(path, ".")
-	lastKey := keys[len(keys)-1]
+// multiplyNumbers multiplies two numeric values using the same widening rules as addNumbers.
+func multiplyNumbers(a, b interface{}) (interface{}, error) {
+	ai, af, aKind, ok := toNumber(a)
+	if !ok {
+		return nil, fmt.Errorf("value %v is not a number", a)
+	}
+	bi, bf, bKind, ok := toNumber(b)
+	if !ok {
+		return nil, fmt.Errorf("value %v is not a number", b)
+	}
 
-	// Traverse to the nested map where the last key belongs
-	m := doc
-	for _, key := range keys[:len(keys)-1] {
-		if nested, ok := m[key].(map[string]interface{}); ok {
-			m = nested
-		} else {
-			// Field doesn't exist, nothing to unset
-			return
+	switch max(aKind, bKind) {
+	case numberFloat64:
+		return af * bf, nil
+	case numberInt64:
+		product := ai * bi
+		if ai != 0 && (product/ai != bi || (ai == -1 && bi == math.MinInt64)) {
+			return nil, fmt.Errorf("integer overflow")
 		}
+		return product, nil
+	default:
+		return narrowInt(ai * bi), nil
+	}
+}
+
+// narrowInt keeps the result of int32 arithmetic as int32 unless it overflows.
+func narrowInt(v int64) interface{} {
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
+		return int32(v)
 	}
-	delete(m, lastKey)
+	return v
 }
 
-func addIndexEntry(txn *badger.Txn, field string, value interface{}, docID string) error {
-	indexKey := fmt.Sprintf("%s|%v|%s", field, value, docID)
-	return txn.Set([]byte(indexKey), []byte(docID))
+func (c *Collection[T]) addIndexEntry(txn *badger.Txn, field string, value interface{}, docID string) error {
+	return txn.Set(c.indexKey(field, value, docID), []byte(docID))
 }
 
-func removeIndexEntry(txn *badger.Txn, field string, value interface{}, docID string) error {
-	indexKey := fmt.Sprintf("%s|%v|%s", field, value, docID)
-	return txn.Delete([]byte(indexKey))
+func (c *Collection[T]) removeIndexEntry(txn *badger.Txn, field string, value interface{}, docID string) error {
+	return txn.Delete(c.indexKey(field, value, docID))
 }
 
 // Example usage of an update operation
@@ -369,11 +655,31 @@ func removeIndexEntry(txn *badger.Txn, field string, value interface{}, docID st
 //			"ratings.score": 1,
 //		},
 //	}
-func (c *Collection[T]) UpdateByID(docID string, update Update) error {
-	return c.Db.Update(func(txn *badger.Txn) error {
-		key := fmt.Sprintf("%s|%s", c.Name, docID)
+func (c *Collection[T]) UpdateByID(docID string, update UpdateSpec, updateOptions ...UpdateOptions) (UpdateResult, error) {
+	var options UpdateOptions
+	if len(updateOptions) > 0 {
+		options = updateOptions[0]
+	}
+
+	compiled, err := compileUpdateSpec(update)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+
+	var result UpdateResult
+	err = c.updateTxn(options.DryRun, func(txn *badger.Txn) error {
+		result = UpdateResult{} // Reset if the transaction is retried
+		key := c.docKey(docID)
 
-		item, err := txn.Get([]byte(key))
+		item, err := txn.Get(key)
+		if errors.Is(err, badger.ErrKeyNotFound) && options.Upsert {
+			doc, err := nativeUpsert(c, txn, Filter{"_id": docID}, compiled, options)
+			if err != nil {
+				return err
+			}
+			result.upserted(doc, options)
+			return nil
+		}
 		if err != nil {
 			return err
 		}
@@ -386,77 +692,181 @@ func (c *Collection[T]) UpdateByID(docID string, update Update) error {
 			return err
 		}
 
-		return nativeUpdate(c, txn, doc, docID, update)
+		err = nativeUpdate(c, txn, doc, docID, compiled, newUpdateContext(Filter{"_id": docID}, options))
+		if err != nil {
+			return err
+		}
+		result.matched(doc, options)
+		return nil
 	})
+	if err != nil {
+		return UpdateResult{}, err
+	}
+
+	return result, nil
 }
 
-func (c *Collection[T]) UpdateOne(filter Filter, update Update) error {
-	return c.Db.Update(func(txn *badger.Txn) error {
-		result := nativeFindOne(c, txn, filter)
-		if !result.found {
-			return errors.New("no document found in result")
-		}
+// ReplaceByID replaces the whole document with docID, keeping its _id (and
+// createdAt when timestamps are enabled). With ExpectedVersion set, the
+// replacement only happens if the stored document is still at that version.
+func (c *Collection[T]) ReplaceByID(docID string, doc T, updateOptions ...UpdateOptions) (UpdateResult, error) {
+	doc.SetID(docID)
 
-		doc := result.doc
-		docID := result.doc["_id"].(string)
+	update, err := c.replacementFor(doc)
+	if err != nil {
+		return UpdateResult{}, err
+	}
 
-		return nativeUpdate(c, txn, doc, docID, update)
-	})
+	return c.UpdateByID(docID, update, updateOptions...)
 }
 
-func (c *Collection[T]) UpdateMany(filter Filter, update Update) error {
-	return c.Db.Update(func(txn *badger.Txn) error {
-		results := nativeFind(c, txn, filter)
+// replacementFor converts a typed document into a replacement update.
+func (c *Collection[T]) replacementFor(doc T) (replacement, error) {
+	serializedDoc, err := bson.Marshal(doc)
+	if err != nil {
+		return replacement{}, err
+	}
+	var replacementDoc map[string]interface{}
+	if err := bson.Unmarshal(serializedDoc, &replacementDoc); err != nil {
+		return replacement{}, err
+	}
 
-		if len(results) == 0 {
-			return nil // No matching documents
-		}
+	return replacement{doc: replacementDoc, keepCreatedAt: c.Timestamp}, nil
+}
 
-		// Create channels to handle concurrency
-		docCh := make(chan map[string]interface{}, len(results)) // Channel to send docs to worker goroutines
-		errCh := make(chan error, len(results))                  // Channel to capture errors
-		workerCount := 5                                         // Number of concurrent workers
-		var wg sync.WaitGroup                                    // WaitGroup for tracking completion
+func (c *Collection[T]) UpdateOne(filter Filter, update UpdateSpec, updateOptions ...UpdateOptions) (UpdateResult, error) {
+	var options UpdateOptions
+	if len(updateOptions) > 0 {
+		options = updateOptions[0]
+	}
 
-		// Start worker goroutines
-		for i := 0; i < workerCount; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				for doc := range docCh {
-					docID := doc["_id"].(string)
+	compiled, err := compileUpdateSpec(update)
+	if err != nil {
+		return UpdateResult{}, err
+	}
 
-					// Update the document within the same transaction
-					err := nativeUpdate(c, txn, doc, docID, update)
-					if err != nil {
-						errCh <- fmt.Errorf("failed to update doc %s: %v", docID, err)
-					}
-				}
-			}()
+	var result UpdateResult
+	err = c.updateTxn(options.DryRun, func(txn *badger.Txn) error {
+		var err error
+		result, err = nativeUpdateOne(c, txn, filter, compiled, options)
+		if err != nil {
+			return err
 		}
-
-		// Send documents to workers
-		for _, doc := range results {
-			docCh <- doc
+		if result.MatchedCount == 0 && result.UpsertedID == "" {
+			return errors.New("no document found in result")
 		}
+		return nil
+	})
+	if err != nil {
+		return UpdateResult{}, err
+	}
+
+	return result, nil
+}
+
+// UpdateMany updates every document matching the filter. By default this
+// happens in a single transaction; with Atomicity set to Chunked the matching
+// documents are updated in bounded transactions and Progress is reported
+// after each one, so a failure leaves the earlier chunks updated.
+func (c *Collection[T]) UpdateMany(filter Filter, update UpdateSpec, updateOptions ...UpdateOptions) (UpdateResult, error) {
+	var options UpdateOptions
+	if len(updateOptions) > 0 {
+		options = updateOptions[0]
+	}
 
-		close(docCh) // Close the doc channel when all documents are sent
+	compiled, err := compileUpdateSpec(update)
+	if err != nil {
+		return UpdateResult{}, err
+	}
 
-		// Wait for all workers to finish
-		wg.Wait()
+	// A bound transaction is already all-or-nothing, and dry runs write nothing
+	if options.Atomicity == Chunked && !options.DryRun && c.tx == nil {
+		return c.updateManyChunked(filter, compiled, options)
+	}
 
-		close(errCh) // Close the error channel
+	var result UpdateResult
+	err = c.updateTxn(options.DryRun, func(txn *badger.Txn) error {
+		var err error
+		result, err = nativeUpdateMany(c, txn, filter, compiled, options)
+		return err
+	})
+	if err != nil {
+		return UpdateResult{}, err
+	}
 
-		// Collect and return any errors
-		var finalErr error
-		for err := range errCh {
-			if finalErr == nil {
-				finalErr = err
-			} else {
-				finalErr = fmt.Errorf("%v; %v", finalErr, err)
-			}
+	if options.Progress != nil && result.MatchedCount > 0 {
+		options.Progress(Progress{Processed: result.MatchedCount, Total: result.MatchedCount})
+	}
+	return result, nil
+}
+
+func (c *Collection[T]) updateManyChunked(filter Filter, compiled compiledUpdate, options UpdateOptions) (UpdateResult, error) {
+	ids, err := c.matchingIDs(filter)
+	if err != nil {
+		return UpdateResult{}, err
+	}
+
+	var result UpdateResult
+	if len(ids) == 0 {
+		if !options.Upsert {
+			return result, nil // No matching documents
 		}
+		err := c.update(func(txn *badger.Txn) error {
+			doc, err := nativeUpsert(c, txn, filter, compiled, options)
+			if err != nil {
+				return err
+			}
+			result = UpdateResult{}
+			result.upserted(doc, options)
+			return nil
+		})
+		return result, err
+	}
+
+	matched, err := c.forEachChunk(ids, options.ChunkSize, options.Progress, func(txn *badger.Txn, ids []string) (int, error) {
+		var matched int
+		for _, docID := range ids {
+			// The document may have changed since the IDs were collected
+			doc, found, err := nativeGet(c, txn, docID)
+			if err != nil {
+				return 0, err
+			}
+			if !found || !matchDocument(doc, filter) {
+				continue
+			}
 
-		return finalErr
+			err = nativeUpdate(c, txn, doc, docID, compiled, newUpdateContext(filter, options))
+			if err != nil {
+				return 0, fmt.Errorf("failed to update doc %s: %w", docID, err)
+			}
+			matched++
+		}
+		return matched, nil
 	})
+	result.MatchedCount = matched
+	return result, err
+}
+
+// updateTxn runs fn in a read-write transaction, or a read-only one for dry runs.
+func (c *Collection[T]) updateTxn(dryRun bool, fn func(txn *badger.Txn) error) error {
+	if dryRun {
+		return c.view(fn)
+	}
+	return c.update(fn)
+}
+
+func (r *UpdateResult) matched(doc map[string]interface{}, options UpdateOptions) {
+	r.MatchedCount++
+	r.Version = documentVersion(doc)
+	if options.DryRun {
+		r.Documents = append(r.Documents, doc)
+	}
+}
+
+func (r *UpdateResult) upserted(doc map[string]interface{}, options UpdateOptions) {
+	r.UpsertedID = documentID(doc)
+	r.Version = documentVersion(doc)
+	if options.DryRun {
+		r.Documents = append(r.Documents, doc)
+	}
 }
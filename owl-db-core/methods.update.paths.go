@@ -0,0 +1,206 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// updateContext carries what is needed to resolve the paths of an update
+// against one document.
+type updateContext struct {
+	inserting    bool              // The document is being created by an upsert
+	filter       Filter            // Query that selected the document, for $
+	arrayFilters map[string]Filter // $[<identifier>] conditions keyed by identifier
+	position     int               // Array index matched by the filter, or -1
+	dryRun       bool              // Compute the result without writing it
+	version      int64             // Expected _v of the document, 0 if unchecked
+}
+
+func newUpdateContext(filter Filter, options UpdateOptions) updateContext {
+	arrayFilters := make(map[string]Filter)
+	for _, arrayFilter := range options.ArrayFilters {
+		for field, condition := range arrayFilter {
+			identifier := strings.SplitN(field, ".", 2)[0]
+			if arrayFilters[identifier] == nil {
+				arrayFilters[identifier] = Filter{}
+			}
+			arrayFilters[identifier][field] = condition
+		}
+	}
+
+	return updateContext{
+		filter:       filter,
+		arrayFilters: arrayFilters,
+		position:     -1,
+		dryRun:       options.DryRun,
+		version:      options.ExpectedVersion,
+	}
+}
+
+// bind records the array position matched by the filter before the document
+// is modified, so every $ in the update refers to the same element.
+func (ctx updateContext) bind(doc map[string]interface{}) updateContext {
+	ctx.position = positionalIndex(doc, ctx.filter)
+	return ctx
+}
+
+// expandPath resolves the positional operators in an update path into the
+// concrete paths it refers to, e.g. "items.$[].qty" -> "items.0.qty", "items.1.qty".
+func (ctx updateContext) expandPath(doc map[string]interface{}, path string) ([]string, error) {
+	return ctx.expandKeys(doc, strings.Split(path, "."), nil)
+}
+
+func (ctx updateContext) expandKeys(current interface{}, keys []string, prefix []string) ([]string, error) {
+	for i, key := range keys {
+		if key == "$" {
+			if ctx.position < 0 {
+				return nil, fmt.Errorf("the positional operator did not find the match needed from the query")
+			}
+			key = strconv.Itoa(ctx.position)
+		} else if strings.HasPrefix(key, "$[") && strings.HasSuffix(key, "]") {
+			identifier := key[2 : len(key)-1]
+			if identifier != "" && ctx.arrayFilters[identifier] == nil {
+				return nil, fmt.Errorf("no array filter found for identifier %q", identifier)
+			}
+
+			array, ok := toArray(current)
+			if !ok {
+				if current == nil {
+					return nil, nil // Nothing to update
+				}
+				return nil, fmt.Errorf("cannot apply %s to non-array field %s", key, strings.Join(prefix, "."))
+			}
+
+			var paths []string
+			for index, element := range array {
+				if identifier != "" && !matchDocument(map[string]interface{}{identifier: element}, ctx.arrayFilters[identifier]) {
+					continue
+				}
+				elementPrefix := append(append([]string{}, prefix...), strconv.Itoa(index))
+				elementPaths, err := ctx.expandKeys(element, keys[i+1:], elementPrefix)
+				if err != nil {
+					return nil, err
+				}
+				paths = append(paths, elementPaths...)
+			}
+			return paths, nil
+		}
+
+		prefix = append(prefix, key)
+		current, _ = childValue(current, key)
+	}
+	return []string{strings.Join(prefix, ".")}, nil
+}
+
+// positionalIndex returns the index of the array element matched by the
+// filter's conditions, or -1 if no condition matched inside an array.
+func positionalIndex(doc map[string]interface{}, filter Filter) int {
+	for field, value := range filter {
+		switch field {
+		case "$and":
+			subFilters, _ := value.([]Filter)
+			for _, subFilter := range subFilters {
+				if index := positionalIndex(doc, subFilter); index >= 0 {
+					return index
+				}
+			}
+		case "$or", "$nor":
+			continue
+		default:
+			if matched, index := matchPath(doc, strings.Split(field, "."), value); matched && index >= 0 {
+				return index
+			}
+		}
+	}
+	return -1
+}
+
+// updateNestedField sets the value at a dotted path, creating missing
+// documents along the way. Numeric segments index into arrays, padding them
+// with nulls when the index is past the end.
+func updateNestedField(doc map[string]interface{}, path string, value interface{}) error {
+	_, err := setPathValue(doc, strings.Split(path, "."), value, path)
+	return err
+}
+
+// setPathValue sets keys inside container (a document or array) and returns
+// the container, which may have been reallocated if it is an array.
+func setPathValue(container interface{}, keys []string, value interface{}, path string) (interface{}, error) {
+	key := keys[0]
+
+	switch c := container.(type) {
+	case map[string]interface{}:
+		if len(keys) == 1 {
+			c[key] = value
+			return c, nil
+		}
+
+		child := c[key]
+		if child == nil {
+			// Create a new nested map if it doesn't exist
+			child = make(map[string]interface{})
+		}
+		child, err := setPathValue(child, keys[1:], value, path)
+		if err != nil {
+			return nil, err
+		}
+		c[key] = child
+		return c, nil
+	}
+
+	array, ok := toArray(container)
+	if !ok {
+		return nil, fmt.Errorf("cannot create field %s in element of type %T", path, container)
+	}
+	index, err := strconv.Atoi(key)
+	if err != nil || index < 0 {
+		return nil, fmt.Errorf("cannot use the part %q of %s to traverse an array", key, path)
+	}
+
+	// Pad with nulls up to the index
+	result := []interface{}(array)
+	for len(result) <= index {
+		result = append(result, nil)
+	}
+
+	if len(keys) == 1 {
+		result[index] = value
+		return result, nil
+	}
+
+	child := result[index]
+	if child == nil {
+		child = make(map[string]interface{})
+	}
+	child, err = setPathValue(child, keys[1:], value, path)
+	if err != nil {
+		return nil, err
+	}
+	result[index] = child
+	return result, nil
+}
+
+// deleteNestedField removes the field at a dotted path. Array elements are
+// set to null rather than removed so the other indexes stay stable.
+func deleteNestedField(doc map[string]interface{}, path string) {
+	keys := strings.Split(path, ".")
+	lastKey := keys[len(keys)-1]
+
+	// Traverse to the container where the last key belongs
+	parent, exists := getNestedValue(doc, keys[:len(keys)-1])
+	if !exists {
+		// Field doesn't exist, nothing to unset
+		return
+	}
+
+	if m, ok := parent.(map[string]interface{}); ok {
+		delete(m, lastKey)
+		return
+	}
+	if array, ok := toArray(parent); ok {
+		if index, err := strconv.Atoi(lastKey); err == nil && index >= 0 && index < len(array) {
+			array[index] = nil
+		}
+	}
+}
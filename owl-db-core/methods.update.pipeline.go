@@ -0,0 +1,243 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Stage is one stage of an update pipeline, e.g.
+//
+//	Stage{"$set": map[string]interface{}{
+//		"total": map[string]interface{}{"$multiply": []interface{}{"$price", "$qty"}},
+//	}}
+type Stage map[string]interface{}
+
+// Pipeline is an aggregation-pipeline style update. Unlike Update, its
+// $set/$addFields, $unset and $project stages are evaluated against the
+// current document, so new values can be computed from other fields. It is
+// also the argument of Aggregate, which supports more stages.
+type Pipeline []Stage
+
+func (p Pipeline) compileUpdate() (compiledUpdate, error) {
+	if len(p) == 0 {
+		return nil, fmt.Errorf("update pipeline must contain at least one stage")
+	}
+
+	for i, stage := range p {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("pipeline stage %d must have exactly one field", i)
+		}
+		for name, spec := range stage {
+			if err := validateStage(name, spec); err != nil {
+				return nil, fmt.Errorf("pipeline stage %d: %v", i, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+func validateStage(name string, spec interface{}) error {
+	switch name {
+	case "$set", "$addFields":
+		fields, ok := spec.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid %s stage", name)
+		}
+		for path := range fields {
+			if err := validatePath(path); err != nil {
+				return err
+			}
+		}
+
+	case "$unset":
+		paths, ok := unsetPaths(spec)
+		if !ok {
+			return fmt.Errorf("$unset stage takes a field path or an array of field paths")
+		}
+		for _, path := range paths {
+			if err := validatePath(path); err != nil {
+				return err
+			}
+		}
+
+	case "$project":
+		fields, ok := spec.(map[string]interface{})
+		if !ok || len(fields) == 0 {
+			return fmt.Errorf("invalid $project stage")
+		}
+		if _, err := projectionMode(fields); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unsupported update pipeline stage: %s", name)
+	}
+	return nil
+}
+
+// apply runs every stage against the document in place.
+func (p Pipeline) apply(doc map[string]interface{}, ctx updateContext) error {
+	docID, hasID := doc["_id"]
+
+	for _, stage := range p {
+		for name, spec := range stage {
+			if err := applyDocumentStage(doc, name, spec); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Updates can never change a document's identity
+	if hasID {
+		if newID, exists := doc["_id"]; exists && !reflect.DeepEqual(newID, docID) {
+			return fmt.Errorf("update pipeline cannot modify _id")
+		}
+		doc["_id"] = docID
+	}
+	return nil
+}
+
+// applyDocumentStage runs a validated $set/$addFields, $unset or $project
+// stage against the document in place.
+func applyDocumentStage(doc map[string]interface{}, name string, spec interface{}) error {
+	switch name {
+	case "$set", "$addFields":
+		return applySetStage(doc, spec.(map[string]interface{}))
+	case "$unset":
+		paths, _ := unsetPaths(spec)
+		for _, path := range paths {
+			deleteNestedField(doc, path)
+		}
+	case "$project":
+		return applyProjectStage(doc, spec.(map[string]interface{}))
+	}
+	return nil
+}
+
+// applySetStage evaluates every expression against the document as it was
+// before the stage, then assigns the results.
+func applySetStage(doc map[string]interface{}, fields map[string]interface{}) error {
+	values := make(map[string]interface{}, len(fields))
+	for path, expr := range fields {
+		value, err := evaluateExpression(expr, doc)
+		if err != nil {
+			return fmt.Errorf("cannot compute %s: %v", path, err)
+		}
+		values[path] = value
+	}
+
+	for path, value := range values {
+		if _, remove := value.(removeValue); remove {
+			deleteNestedField(doc, path)
+			continue
+		}
+		if err := updateNestedField(doc, path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyProjectStage reshapes the document. In inclusion mode only _id, the
+// included fields and the computed fields remain; in exclusion mode the
+// listed fields are removed.
+func applyProjectStage(doc map[string]interface{}, fields map[string]interface{}) error {
+	inclusion, _ := projectionMode(fields)
+
+	if !inclusion {
+		for path := range fields {
+			deleteNestedField(doc, path)
+		}
+		return nil
+	}
+
+	projected := make(map[string]interface{})
+	if id, exists := doc["_id"]; exists {
+		projected["_id"] = id
+	}
+	for path, spec := range fields {
+		if include, isFlag := projectionFlag(spec); isFlag {
+			if !include {
+				delete(projected, path) // Only _id may be excluded in inclusion mode
+				continue
+			}
+			if value, exists := getNestedValue(doc, strings.Split(path, ".")); exists {
+				if err := updateNestedField(projected, path, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		value, err := evaluateExpression(spec, doc)
+		if err != nil {
+			return fmt.Errorf("cannot compute %s: %v", path, err)
+		}
+		if _, remove := value.(removeValue); remove {
+			continue
+		}
+		if err := updateNestedField(projected, path, value); err != nil {
+			return err
+		}
+	}
+
+	// Replace the document's contents in place
+	for key := range doc {
+		delete(doc, key)
+	}
+	for key, value := range projected {
+		doc[key] = value
+	}
+	return nil
+}
+
+// projectionMode reports whether a $project is an inclusion (true) or an
+// exclusion (false) projection. Only _id may be excluded from an inclusion.
+func projectionMode(fields map[string]interface{}) (bool, error) {
+	var includes, excludes bool
+	for path, spec := range fields {
+		include, isFlag := projectionFlag(spec)
+		switch {
+		case !isFlag || include:
+			includes = true
+		case path != "_id":
+			excludes = true
+		}
+	}
+	if includes && excludes {
+		return false, fmt.Errorf("cannot mix inclusion and exclusion in $project")
+	}
+	// {"_id": 0} alone is an exclusion
+	return includes, nil
+}
+
+// projectionFlag interprets 1/0/true/false projection values.
+func projectionFlag(spec interface{}) (include bool, isFlag bool) {
+	if b, ok := spec.(bool); ok {
+		return b, true
+	}
+	if _, f, _, ok := toNumber(spec); ok {
+		return f != 0, true
+	}
+	return false, false
+}
+
+func unsetPaths(spec interface{}) ([]string, bool) {
+	if path, ok := spec.(string); ok {
+		return []string{path}, true
+	}
+	array, ok := toArray(spec)
+	if !ok {
+		return nil, false
+	}
+	paths := make([]string, len(array))
+	for i, element := range array {
+		path, ok := element.(string)
+		if !ok {
+			return nil, false
+		}
+		paths[i] = path
+	}
+	return paths, true
+}
@@ -0,0 +1,86 @@
+package core_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPipelineSetRootStoresACopy(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products")
+	if err := products.Insert(&product{ID: "p1", Name: "lamp", Price: 12}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored copy must not contain itself, or encoding it never ends
+	_, err := products.UpdateByID("p1", core.Pipeline{{"$set": map[string]interface{}{"copy": "$$ROOT"}}})
+	if err != nil {
+		t.Fatalf("UpdateByID: %v", err)
+	}
+
+	doc, err := products.FindByID("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, ok := doc["copy"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("copy = %#v, want a document", doc["copy"])
+	}
+	if copied["name"] != "lamp" || copied["price"] != 12.0 {
+		t.Errorf("copy = %v, want the document before the update", copied)
+	}
+	if _, nested := copied["copy"]; nested {
+		t.Errorf("copy contains itself: %v", copied)
+	}
+}
+
+func TestPipelineExpressionArguments(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	doc := bson.D{
+		{Key: "price", Value: 3},
+		{Key: "qty", Value: 4},
+		{Key: "at", Value: primitive.NewDateTimeFromTime(at)},
+	}
+
+	for _, test := range []struct {
+		name    string
+		expr    interface{}
+		want    string
+		wantErr string
+	}{
+		{"array", map[string]interface{}{"$multiply": []interface{}{"$price", "$qty"}}, "12", ""},
+		{"bson array", map[string]interface{}{"$multiply": primitive.A{"$price", "$qty"}}, "12", ""},
+		{"nested bson array", map[string]interface{}{"$add": primitive.A{"$price", map[string]interface{}{"$multiply": primitive.A{"$qty", 2}}}}, "11", ""},
+		{"bson array condition", map[string]interface{}{"$cond": primitive.A{map[string]interface{}{"$gt": primitive.A{"$qty", 3}}, "many", "few"}}, "many", ""},
+		{"date minus milliseconds", map[string]interface{}{"$subtract": primitive.A{"$at", 60000}}, "2024-05-01 11:59:00 +0000 UTC", ""},
+		{"date minus date", map[string]interface{}{"$subtract": []interface{}{"$at", at.Add(-time.Second)}}, "1000", ""},
+		{"date minus string", map[string]interface{}{"$subtract": []interface{}{"$at", "1h"}}, "", "$subtract only supports"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stored, err := applyUpdate(t, doc, core.Pipeline{{"$set": map[string]interface{}{"result": test.expr}}})
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateByID: %v", err)
+			}
+
+			result := stored["result"]
+			if date, ok := result.(primitive.DateTime); ok {
+				result = date.Time().UTC()
+			}
+			if got := fmt.Sprint(result); got != test.want {
+				t.Errorf("result = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
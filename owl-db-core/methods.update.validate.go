@@ -0,0 +1,140 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// updateOperation is a single operator applied to a single path.
+type updateOperation struct {
+	op    string
+	path  string
+	value interface{}
+}
+
+// Supported update operators, in the order they are applied
+var updateOperators = []string{
+	"$setOnInsert", "$set", "$unset", "$rename", "$inc", "$mul", "$min", "$max",
+	"$currentDate", "$push", "$addToSet", "$pull", "$pullAll", "$pop",
+}
+
+// normalizeUpdate validates an update before anything is modified and returns
+// its operations in a deterministic order. It rejects unknown operators,
+// malformed operands and operations whose paths conflict with each other.
+func normalizeUpdate(update Update) ([]updateOperation, error) {
+	if len(update) == 0 {
+		return nil, fmt.Errorf("update document must contain at least one operator")
+	}
+
+	operatorRank := make(map[string]int, len(updateOperators))
+	for rank, op := range updateOperators {
+		operatorRank[op] = rank
+	}
+
+	var operations []updateOperation
+	for op, fields := range update {
+		if _, ok := operatorRank[op]; !ok {
+			return nil, fmt.Errorf("unsupported update operator: %s", op)
+		}
+		operatorFields, ok := fields.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid %s operation", op)
+		}
+
+		for path, value := range operatorFields {
+			if err := validatePath(path); err != nil {
+				return nil, fmt.Errorf("invalid %s operation: %v", op, err)
+			}
+			if err := validateOperand(op, value); err != nil {
+				return nil, err
+			}
+			operations = append(operations, updateOperation{op: op, path: path, value: value})
+		}
+	}
+
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].op != operations[j].op {
+			return operatorRank[operations[i].op] < operatorRank[operations[j].op]
+		}
+		return operations[i].path < operations[j].path
+	})
+
+	// Every path touched by the update, including $rename destinations
+	type touchedPath struct {
+		op   string
+		path string
+	}
+	var touched []touchedPath
+	for _, operation := range operations {
+		touched = append(touched, touchedPath{operation.op, operation.path})
+		if operation.op == "$rename" {
+			newPath := operation.value.(string)
+			if err := validatePath(newPath); err != nil {
+				return nil, fmt.Errorf("invalid $rename operation: %v", err)
+			}
+			touched = append(touched, touchedPath{operation.op, newPath})
+		}
+	}
+
+	for i := range touched {
+		for j := i + 1; j < len(touched); j++ {
+			if pathsConflict(touched[i].path, touched[j].path) {
+				return nil, fmt.Errorf(
+					"updating the path %q with %s would create a conflict at %q with %s",
+					touched[j].path, touched[j].op, touched[i].path, touched[i].op,
+				)
+			}
+		}
+	}
+
+	return operations, nil
+}
+
+// validatePath rejects empty paths and paths with empty segments.
+func validatePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("empty field path")
+	}
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			return fmt.Errorf("field path %q contains an empty segment", path)
+		}
+	}
+	return nil
+}
+
+// validateOperand checks the operand shapes that can be checked without the document.
+func validateOperand(op string, value interface{}) error {
+	switch op {
+	case "$inc", "$mul":
+		if _, _, _, ok := toNumber(value); !ok {
+			return fmt.Errorf("%s requires a numeric value, got %T", op, value)
+		}
+	case "$rename":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("new key in $rename must be a string")
+		}
+	case "$pop":
+		if _, ok := toDirection(value); !ok {
+			return fmt.Errorf("invalid value for $pop operation")
+		}
+	case "$pullAll":
+		if _, ok := toArray(value); !ok {
+			return fmt.Errorf("invalid value for $pullAll operation")
+		}
+	case "$currentDate":
+		if _, err := currentDateValue(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pathsConflict reports whether two paths are equal or one contains the other.
+func pathsConflict(a, b string) bool {
+	if a == b {
+		return true
+	}
+	return strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
@@ -0,0 +1,451 @@
+package core_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// applyUpdate stores doc with the _id "d1", updates it and returns the
+// stored result.
+func applyUpdate(t *testing.T, doc bson.D, update core.UpdateSpec) (map[string]interface{}, error) {
+	t.Helper()
+	return applyUpdateOne(t, doc, core.Filter{"_id": "d1"}, update)
+}
+
+// applyUpdateOne stores doc with the _id "d1", updates it through UpdateOne
+// and returns the stored result.
+func applyUpdateOne(t *testing.T, doc bson.D, filter core.Filter, update core.UpdateSpec, options ...core.UpdateOptions) (map[string]interface{}, error) {
+	t.Helper()
+
+	docs := core.NewCollection[*core.RawDocument](testutil.OpenDB(t), "docs")
+	raw := core.RawDocument(append(bson.D{{Key: "_id", Value: "d1"}}, doc...))
+	if err := docs.Insert(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := docs.UpdateOne(filter, update, options...); err != nil {
+		return nil, err
+	}
+
+	stored, err := docs.FindByID("d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored, nil
+}
+
+// updateTest is an update and the value it should leave in the items field,
+// or the error it should fail with.
+type updateTest struct {
+	name    string
+	update  core.Update
+	want    string
+	wantErr string
+}
+
+// runUpdateTests applies the update of each test to a copy of doc.
+func runUpdateTests(t *testing.T, doc bson.D, tests []updateTest) {
+	t.Helper()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stored, err := applyUpdate(t, doc, test.update)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateByID: %v", err)
+			}
+			if got := fmt.Sprint(stored["items"]); got != test.want {
+				t.Errorf("items = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePull(t *testing.T) {
+	doc := bson.D{{Key: "items", Value: bson.A{
+		bson.D{{Key: "q", Value: 5}, {Key: "tag", Value: "a"}},
+		bson.D{{Key: "q", Value: 6}, {Key: "tag", Value: "b"}},
+		bson.D{{Key: "q", Value: 8}, {Key: "tag", Value: "a"}},
+	}}}
+	runUpdateTests(t, doc, []updateTest{
+		{
+			name:   "nested operator map",
+			update: core.Update{"$pull": map[string]interface{}{"items": map[string]interface{}{"q": map[string]interface{}{"$gte": 6}}}},
+			want:   "[map[q:5 tag:a]]",
+		},
+		{
+			name:   "nested operator Filter",
+			update: core.Update{"$pull": map[string]interface{}{"items": core.Filter{"q": core.Filter{"$lt": 6}}}},
+			want:   "[map[q:6 tag:b] map[q:8 tag:a]]",
+		},
+		{
+			name:   "field equality",
+			update: core.Update{"$pull": map[string]interface{}{"items": map[string]interface{}{"tag": "a"}}},
+			want:   "[map[q:6 tag:b]]",
+		},
+		{
+			name:   "no match",
+			update: core.Update{"$pull": map[string]interface{}{"items": map[string]interface{}{"q": map[string]interface{}{"$gt": 8}}}},
+			want:   "[map[q:5 tag:a] map[q:6 tag:b] map[q:8 tag:a]]",
+		},
+	})
+
+	runUpdateTests(t, bson.D{{Key: "items", Value: bson.A{1, 5, 6, 9}}}, []updateTest{
+		{
+			name:   "operators on values",
+			update: core.Update{"$pull": map[string]interface{}{"items": map[string]interface{}{"$gte": 6}}},
+			want:   "[1 5]",
+		},
+		{
+			name:   "value",
+			update: core.Update{"$pull": map[string]interface{}{"items": 5}},
+			want:   "[1 6 9]",
+		},
+	})
+}
+
+func TestUpdatePushModifiers(t *testing.T) {
+	runUpdateTests(t, bson.D{{Key: "items", Value: bson.A{3, 1, 4}}}, []updateTest{
+		{
+			name:   "value",
+			update: core.Update{"$push": map[string]interface{}{"items": 5}},
+			want:   "[3 1 4 5]",
+		},
+		{
+			name:   "each",
+			update: core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{"$each": []interface{}{5, 9}}}},
+			want:   "[3 1 4 5 9]",
+		},
+		{
+			name:   "position",
+			update: core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{"$each": []interface{}{5}, "$position": 1}}},
+			want:   "[3 5 1 4]",
+		},
+		{
+			name:   "negative position",
+			update: core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{"$each": []interface{}{5}, "$position": -1}}},
+			want:   "[3 1 5 4]",
+		},
+		{
+			name:   "sort",
+			update: core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{"$each": []interface{}{2}, "$sort": -1}}},
+			want:   "[4 3 2 1]",
+		},
+		{
+			name:   "sort and slice",
+			update: core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{"$each": []interface{}{2}, "$sort": 1, "$slice": 3}}},
+			want:   "[1 2 3]",
+		},
+		{
+			name:   "negative slice",
+			update: core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{"$each": []interface{}{5}, "$slice": -2}}},
+			want:   "[4 5]",
+		},
+		{
+			name:    "unknown modifier",
+			update:  core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{"$each": []interface{}{5}, "$limit": 1}}},
+			wantErr: "unsupported $push modifier",
+		},
+		{
+			name:    "fractional slice",
+			update:  core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{"$each": []interface{}{5}, "$slice": 1.5}}},
+			wantErr: "$slice in $push must be an integer",
+		},
+	})
+
+	doc := bson.D{{Key: "items", Value: bson.A{
+		bson.D{{Key: "q", Value: 2}, {Key: "tag", Value: "b"}},
+		bson.D{{Key: "q", Value: 1}, {Key: "tag", Value: "a"}},
+	}}}
+	runUpdateTests(t, doc, []updateTest{{
+		name: "sort documents",
+		update: core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{
+			"$each": []interface{}{map[string]interface{}{"q": 3, "tag": "c"}},
+			"$sort": map[string]interface{}{"q": -1},
+		}}},
+		want: "[map[q:3 tag:c] map[q:2 tag:b] map[q:1 tag:a]]",
+	}})
+}
+
+func TestUpdateManyChunkedSplitsLargeChunks(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products")
+
+	// 2000 documents of 10KB are too many for one Badger transaction
+	name := strings.Repeat("x", 10<<10)
+	docs := make([]*product, 2000)
+	for i := range docs {
+		docs[i] = &product{ID: fmt.Sprintf("p%04d", i), Name: name}
+	}
+	chunked := core.InsertManyOptions{Atomicity: core.Chunked, ChunkSize: len(docs)}
+	if _, err := products.InsertMany(docs, chunked); err != nil {
+		t.Fatalf("InsertMany: %v", err)
+	}
+
+	result, err := products.UpdateMany(core.Filter{}, core.Update{"$set": map[string]interface{}{"qty": 1}}, core.UpdateOptions{
+		Atomicity: core.Chunked,
+		ChunkSize: len(docs),
+	})
+	if err != nil {
+		t.Fatalf("UpdateMany: %v", err)
+	}
+	if result.MatchedCount != len(docs) {
+		t.Errorf("MatchedCount = %d, want %d", result.MatchedCount, len(docs))
+	}
+
+	updated, err := products.Find(core.Filter{"qty": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != len(docs) {
+		t.Errorf("%d documents updated, want %d", len(updated), len(docs))
+	}
+}
+
+func TestUpdateNumericOperators(t *testing.T) {
+	doc := bson.D{{Key: "n", Value: int32(4)}, {Key: "big", Value: int64(6)}}
+
+	for _, test := range []struct {
+		name   string
+		update core.Update
+		field  string
+		want   string
+	}{
+		{"mul int32", core.Update{"$mul": map[string]interface{}{"n": 3}}, "n", "int32(12)"},
+		{"mul int64", core.Update{"$mul": map[string]interface{}{"big": int32(2)}}, "big", "int64(12)"},
+		{"mul float", core.Update{"$mul": map[string]interface{}{"n": 0.5}}, "n", "float64(2)"},
+		{"mul missing field", core.Update{"$mul": map[string]interface{}{"gone": 2.5}}, "gone", "float64(0)"},
+		{"min lower", core.Update{"$min": map[string]interface{}{"n": 1.5}}, "n", "float64(1.5)"},
+		{"min higher", core.Update{"$min": map[string]interface{}{"n": 9}}, "n", "int32(4)"},
+		{"max higher", core.Update{"$max": map[string]interface{}{"big": 9}}, "big", "int32(9)"},
+		{"max lower", core.Update{"$max": map[string]interface{}{"big": 2}}, "big", "int64(6)"},
+		{"max missing field", core.Update{"$max": map[string]interface{}{"gone": 7}}, "gone", "int32(7)"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stored, err := applyUpdate(t, doc, test.update)
+			if err != nil {
+				t.Fatalf("UpdateByID: %v", err)
+			}
+			if got := fmt.Sprintf("%T(%v)", stored[test.field], stored[test.field]); got != test.want {
+				t.Errorf("%s = %s, want %s", test.field, got, test.want)
+			}
+		})
+	}
+
+	if _, err := applyUpdate(t, bson.D{{Key: "n", Value: "four"}}, core.Update{"$mul": map[string]interface{}{"n": 2}}); err == nil {
+		t.Error("$mul of a string succeeded")
+	}
+}
+
+func TestUpdateCurrentDate(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+
+	stored, err := applyUpdate(t, nil, core.Update{"$currentDate": map[string]interface{}{
+		"date":  true,
+		"typed": map[string]interface{}{"$type": "date"},
+		"ts":    map[string]interface{}{"$type": "timestamp"},
+	}})
+	if err != nil {
+		t.Fatalf("UpdateByID: %v", err)
+	}
+	for _, field := range []string{"date", "typed"} {
+		date, ok := stored[field].(primitive.DateTime)
+		if !ok || date.Time().Before(before) {
+			t.Errorf("%s = %T(%v), want the current date", field, stored[field], stored[field])
+		}
+	}
+	if ts, ok := stored["ts"].(primitive.Timestamp); !ok || int64(ts.T) < before.Unix() {
+		t.Errorf("ts = %T(%v), want the current timestamp", stored["ts"], stored["ts"])
+	}
+
+	if _, err := applyUpdate(t, nil, core.Update{"$currentDate": map[string]interface{}{"date": map[string]interface{}{"$type": "time"}}}); err == nil {
+		t.Error("$currentDate of an unknown $type succeeded")
+	}
+}
+
+func TestUpdatePop(t *testing.T) {
+	runUpdateTests(t, bson.D{{Key: "items", Value: bson.A{1, 2, 3}}}, []updateTest{
+		{name: "last", update: core.Update{"$pop": map[string]interface{}{"items": 1}}, want: "[1 2]"},
+		{name: "first", update: core.Update{"$pop": map[string]interface{}{"items": -1.0}}, want: "[2 3]"},
+		{name: "fraction", update: core.Update{"$pop": map[string]interface{}{"items": 1.5}}, wantErr: "invalid value for $pop"},
+		{name: "zero", update: core.Update{"$pop": map[string]interface{}{"items": 0}}, wantErr: "invalid value for $pop"},
+		{
+			name:    "fractional sort",
+			update:  core.Update{"$push": map[string]interface{}{"items": map[string]interface{}{"$each": []interface{}{4}, "$sort": 1.5}}},
+			wantErr: "$sort in $push must be 1 or -1",
+		},
+	})
+}
+
+func TestUpdateArrayPaths(t *testing.T) {
+	doc := bson.D{{Key: "items", Value: bson.A{
+		bson.D{{Key: "id", Value: "1"}, {Key: "qty", Value: 2}},
+		bson.D{{Key: "id", Value: "2"}, {Key: "qty", Value: 5}},
+		bson.D{{Key: "id", Value: "3"}, {Key: "qty", Value: 8}},
+	}}}
+	atLeastFive := core.UpdateOptions{ArrayFilters: []core.Filter{{"big.qty": core.Filter{"$gte": 5}}}}
+
+	for _, test := range []struct {
+		name    string
+		filter  core.Filter
+		update  core.Update
+		options core.UpdateOptions
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "index",
+			update: core.Update{"$set": map[string]interface{}{"items.1.qty": 0}},
+			want:   "[map[id:1 qty:2] map[id:2 qty:0] map[id:3 qty:8]]",
+		},
+		{
+			name:   "index past the end",
+			update: core.Update{"$set": map[string]interface{}{"items.4": "x"}},
+			want:   "[map[id:1 qty:2] map[id:2 qty:5] map[id:3 qty:8] <nil> x]",
+		},
+		{
+			name:   "positional",
+			filter: core.Filter{"items.id": "2"},
+			update: core.Update{"$inc": map[string]interface{}{"items.$.qty": 1}},
+			want:   "[map[id:1 qty:2] map[id:2 qty:6] map[id:3 qty:8]]",
+		},
+		{
+			name:   "positional with an operator",
+			filter: core.Filter{"items.qty": core.Filter{"$gt": 6}},
+			update: core.Update{"$set": map[string]interface{}{"items.$.id": "x"}},
+			want:   "[map[id:1 qty:2] map[id:2 qty:5] map[id:x qty:8]]",
+		},
+		{
+			name:    "positional without an array condition",
+			update:  core.Update{"$set": map[string]interface{}{"items.$.qty": 0}},
+			wantErr: "positional operator did not find the match",
+		},
+		{
+			name:   "all elements",
+			update: core.Update{"$inc": map[string]interface{}{"items.$[].qty": 1}},
+			want:   "[map[id:1 qty:3] map[id:2 qty:6] map[id:3 qty:9]]",
+		},
+		{
+			name:    "filtered elements",
+			update:  core.Update{"$set": map[string]interface{}{"items.$[big].qty": 0}},
+			options: atLeastFive,
+			want:    "[map[id:1 qty:2] map[id:2 qty:0] map[id:3 qty:0]]",
+		},
+		{
+			name:    "filter without an identifier",
+			update:  core.Update{"$set": map[string]interface{}{"items.$[small].qty": 0}},
+			options: atLeastFive,
+			wantErr: `no array filter found for identifier "small"`,
+		},
+		{
+			name:    "all elements of a non-array",
+			update:  core.Update{"$set": map[string]interface{}{"items.0.id.$[]": 0}},
+			wantErr: "cannot apply $[] to non-array field items.0.id",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			filter := test.filter
+			if filter == nil {
+				filter = core.Filter{"_id": "d1"}
+			}
+			stored, err := applyUpdateOne(t, doc, filter, test.update, test.options)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateOne: %v", err)
+			}
+			if got := fmt.Sprint(stored["items"]); got != test.want {
+				t.Errorf("items = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdateValidation(t *testing.T) {
+	doc := bson.D{{Key: "items", Value: bson.A{1, 2}}, {Key: "n", Value: 1}}
+
+	for _, test := range []struct {
+		name    string
+		update  core.Update
+		wantErr string
+	}{
+		{"same path", core.Update{"$set": map[string]interface{}{"n": 2}, "$unset": map[string]interface{}{"n": ""}}, "would create a conflict"},
+		{"parent path", core.Update{"$set": map[string]interface{}{"items": bson.A{}}, "$pop": map[string]interface{}{"items.0": 1}}, "would create a conflict"},
+		{"rename destination", core.Update{"$rename": map[string]interface{}{"n": "m"}, "$set": map[string]interface{}{"m": 3}}, "would create a conflict"},
+		{"unknown operator", core.Update{"$inc": map[string]interface{}{"n": 1}, "$bump": map[string]interface{}{"n": 1}}, "unsupported update operator: $bump"},
+		{"bad operand", core.Update{"$inc": map[string]interface{}{"n": "one"}, "$set": map[string]interface{}{"m": 1}}, "$inc"},
+		{"empty path segment", core.Update{"$set": map[string]interface{}{"items..q": 1}}, "invalid $set operation"},
+		{"empty update", core.Update{}, "at least one operator"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			docs := core.NewCollection[*core.RawDocument](testutil.OpenDB(t), "docs")
+			raw := core.RawDocument(append(bson.D{{Key: "_id", Value: "d1"}}, doc...))
+			if err := docs.Insert(&raw); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := docs.UpdateByID("d1", test.update)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("error = %v, want %q", err, test.wantErr)
+			}
+
+			// Nothing is applied when the update is rejected
+			stored, err := docs.FindByID("d1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(stored); got != "map[_id:d1 items:[1 2] n:1]" {
+				t.Errorf("stored %s after a rejected update", got)
+			}
+		})
+	}
+}
+
+func TestUpdateDryRun(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products")
+	testutil.Seed(t, products, "testdata/products.json")
+
+	dryRun := core.UpdateOptions{DryRun: true}
+	result, err := products.UpdateMany(core.Filter{}, core.Update{"$set": map[string]interface{}{"price": 1.0}}, dryRun)
+	if err != nil {
+		t.Fatalf("UpdateMany: %v", err)
+	}
+	if result.MatchedCount != 3 || len(result.Documents) != 3 {
+		t.Fatalf("MatchedCount = %d with %d documents, want 3", result.MatchedCount, len(result.Documents))
+	}
+	for _, doc := range result.Documents {
+		if doc["price"] != 1.0 {
+			t.Errorf("dry run document %v, want the new price", doc)
+		}
+	}
+
+	result, err = products.UpdateOne(core.Filter{"_id": "p9"}, core.Update{"$set": map[string]interface{}{"name": "rug"}}, core.UpdateOptions{DryRun: true, Upsert: true})
+	if err != nil {
+		t.Fatalf("UpdateOne: %v", err)
+	}
+	if result.UpsertedID != "p9" || len(result.Documents) != 1 || result.Documents[0]["name"] != "rug" {
+		t.Errorf("dry run upsert = %+v, want the rug", result)
+	}
+
+	// Nothing was written
+	if doc, err := products.FindByID("p1"); err != nil || doc["price"] != 12.5 {
+		t.Errorf("FindByID(p1) after a dry run = %v, %v, want the old price", doc, err)
+	}
+	if got := storedIDs(t, products); got != "[p1 p2 p3]" {
+		t.Errorf("collection holds %s after a dry run upsert, want [p1 p2 p3]", got)
+	}
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrVersionMismatch is returned when the stored document's version differs
+// from the version the caller expected to update.
+var ErrVersionMismatch = errors.New("document version mismatch")
+
+// versionField holds the document version in versioned collections
+const versionField = "_v"
+
+// documentVersion returns the stored version of a document, 0 if it has none.
+func documentVersion(doc map[string]interface{}) int64 {
+	version, _, _, _ := toNumber(doc[versionField])
+	return version
+}
+
+// checkVersion compares the stored version with the expected one. An expected
+// version of 0 skips the check.
+func (c *Collection[T]) checkVersion(doc map[string]interface{}, expected int64) error {
+	if expected == 0 {
+		return nil
+	}
+	if !c.Versioning {
+		return fmt.Errorf("collection %s does not use versioning", c.Name)
+	}
+	if current := documentVersion(doc); current != expected {
+		return fmt.Errorf("%w: expected %d, found %d", ErrVersionMismatch, expected, current)
+	}
+	return nil
+}
+
+// stampVersion sets the version field of a serialized document.
+func stampVersion(serialized []byte, version int64) ([]byte, error) {
+	var doc bson.D
+	if err := bson.Unmarshal(serialized, &doc); err != nil {
+		return nil, err
+	}
+
+	for i := range doc {
+		if doc[i].Key == versionField {
+			doc[i].Value = version
+			return bson.Marshal(doc)
+		}
+	}
+	return bson.Marshal(append(doc, bson.E{Key: versionField, Value: version}))
+}
@@ -0,0 +1,30 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+func TestUpdateManyVersionMismatch(t *testing.T) {
+	products := core.NewCollection[*product](testutil.OpenDB(t), "products", core.CollectionOptions{Versioning: true})
+	if err := products.Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+		t.Fatal(err)
+	}
+
+	update := core.Update{"$set": map[string]interface{}{"price": 9.5}}
+	_, err := products.UpdateMany(core.Filter{"name": "lamp"}, update, core.UpdateOptions{ExpectedVersion: 2})
+	if !errors.Is(err, core.ErrVersionMismatch) {
+		t.Fatalf("UpdateMany error = %v, want ErrVersionMismatch", err)
+	}
+
+	doc, err := products.FindByID("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["price"] != 0.0 {
+		t.Errorf("document updated despite the version mismatch: %v", doc)
+	}
+}
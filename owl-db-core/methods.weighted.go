@@ -0,0 +1,125 @@
+package core
+
+import (
+	"container/heap"
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+type PathOptions struct {
+	Labels     []string // Edge labels to follow, every declared label if empty
+	EdgeFilter Filter   // Only edges whose properties match are followed
+}
+
+// WeightedShortestPath returns the IDs of the nodes on the cheapest directed
+// path from fromID to toID, both included, and its total weight. The weight of
+// an edge is its numeric weightField property, which must be present and not
+// negative on every edge the search follows. It runs Dijkstra's algorithm
+// within a single read transaction and returns ErrPathNotFound if toID is
+// unreachable.
+func (c *Collection[T]) WeightedShortestPath(fromID, toID, weightField string, opts ...PathOptions) ([]string, float64, error) {
+	var options PathOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if err := c.checkEdgeLabels(options.Labels); err != nil {
+		return nil, 0, err
+	}
+
+	var path []string
+	var total float64
+	err := c.view(func(txn *badger.Txn) error {
+		path, total = nil, 0
+
+		for _, docID := range []string{fromID, toID} {
+			_, found, err := nativeGet(c, txn, docID)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("document %s not found", docID)
+			}
+		}
+
+		distance := map[string]float64{fromID: 0}
+		parent := map[string]string{fromID: ""}
+		settled := make(map[string]bool)
+		queue := &pathQueue{{id: fromID, distance: 0}}
+
+		for queue.Len() > 0 {
+			current := heap.Pop(queue).(pathEntry)
+			if settled[current.id] {
+				continue // A shorter distance was already settled
+			}
+			settled[current.id] = true
+
+			if current.id == toID {
+				for id := toID; id != ""; id = parent[id] {
+					path = append([]string{id}, path...)
+				}
+				total = current.distance
+				return nil
+			}
+
+			edges, err := nativeAdjacentEdges(c, txn, current.id, options.Labels, Outbound, options.EdgeFilter)
+			if err != nil {
+				return err
+			}
+			for _, edge := range edges {
+				weight, err := edgeWeight(edge, weightField)
+				if err != nil {
+					return err
+				}
+
+				candidate := current.distance + weight
+				if known, ok := distance[edge.To]; !ok || candidate < known {
+					distance[edge.To] = candidate
+					parent[edge.To] = current.id
+					heap.Push(queue, pathEntry{id: edge.To, distance: candidate})
+				}
+			}
+		}
+		return ErrPathNotFound
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return path, total, nil
+}
+
+// edgeWeight reads the weight of an edge from its properties.
+func edgeWeight(edge Edge, weightField string) (float64, error) {
+	value, exists := edge.Props[weightField]
+	if !exists {
+		return 0, fmt.Errorf("edge %s -%s-> %s has no %s property", edge.From, edge.Label, edge.To, weightField)
+	}
+
+	_, weight, _, ok := toNumber(value)
+	if !ok {
+		return 0, fmt.Errorf("edge %s -%s-> %s has a non-numeric %s: %v", edge.From, edge.Label, edge.To, weightField, value)
+	}
+	if weight < 0 {
+		return 0, fmt.Errorf("edge %s -%s-> %s has a negative %s: %v", edge.From, edge.Label, edge.To, weightField, value)
+	}
+	return weight, nil
+}
+
+type pathEntry struct {
+	id       string
+	distance float64
+}
+
+// pathQueue is a min-heap of nodes by tentative distance, for container/heap.
+type pathQueue []pathEntry
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].distance < q[j].distance }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathEntry)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
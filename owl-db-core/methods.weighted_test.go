@@ -0,0 +1,88 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	testutil "github.com/TimiBolu/owl-db/owl-db-testutil"
+)
+
+// km returns edge properties with a length and whether the edge is open.
+func km(length float64, open bool) map[string]interface{} {
+	return map[string]interface{}{"km": length, "open": open}
+}
+
+// openWeightedRoutes opens the graph
+//
+//	a -1-> b -1-> c -1-> d    e
+//	a ----5-----> c
+//	a ---------10-------> d (rail)
+//
+// where the c -> d road is closed.
+func openWeightedRoutes(t *testing.T) *core.Collection[*product] {
+	return openGraph(t, testutil.OpenDB(t), "a b c d e", []graphEdge{
+		{"a", "road", "b", km(1, true)},
+		{"b", "road", "c", km(1, true)},
+		{"a", "road", "c", km(5, true)},
+		{"c", "road", "d", km(1, false)},
+		{"a", "rail", "d", km(10, true)},
+	})
+}
+
+func TestWeightedShortestPath(t *testing.T) {
+	nodes := openWeightedRoutes(t)
+
+	for _, test := range []struct {
+		name      string
+		from, to  string
+		options   core.PathOptions
+		want      string
+		wantTotal float64
+	}{
+		{"cheapest", "a", "d", core.PathOptions{}, "[a b c d]", 3},
+		{"labels", "a", "c", core.PathOptions{Labels: []string{"road"}}, "[a b c]", 2},
+		{"edge filter", "a", "d", core.PathOptions{EdgeFilter: core.Filter{"open": true}}, "[a d]", 10},
+		{"same node", "a", "a", core.PathOptions{}, "[a]", 0},
+	} {
+		path, total, err := nodes.WeightedShortestPath(test.from, test.to, "km", test.options)
+		if err != nil {
+			t.Fatalf("%s: WeightedShortestPath: %v", test.name, err)
+		}
+		if got := fmt.Sprint(path); got != test.want || total != test.wantTotal {
+			t.Errorf("%s: path %s of %v km, want %s of %v km", test.name, got, total, test.want, test.wantTotal)
+		}
+	}
+
+	if _, _, err := nodes.WeightedShortestPath("a", "e", "km"); !errors.Is(err, core.ErrPathNotFound) {
+		t.Errorf("WeightedShortestPath to an isolated node = %v, want ErrPathNotFound", err)
+	}
+	if _, _, err := nodes.WeightedShortestPath("a", "d", "minutes"); err == nil || !strings.Contains(err.Error(), "no minutes property") {
+		t.Errorf("WeightedShortestPath over edges without the weight = %v, want an error", err)
+	}
+}
+
+func TestWeightedShortestPathRejectsNegativeWeights(t *testing.T) {
+	nodes := openGraph(t, testutil.OpenDB(t), "a b", []graphEdge{{"a", "road", "b", km(-1, true)}})
+
+	if _, _, err := nodes.WeightedShortestPath("a", "b", "km"); err == nil || !strings.Contains(err.Error(), "negative km") {
+		t.Errorf("WeightedShortestPath over a negative weight = %v, want an error", err)
+	}
+}
+
+func TestTraverseEdgeFilter(t *testing.T) {
+	nodes := openWeightedRoutes(t)
+
+	visited, err := nodes.Traverse("a", core.TraversalOptions{
+		Labels:     []string{"road"},
+		EdgeFilter: core.Filter{"open": true},
+	})
+	if err != nil {
+		t.Fatalf("Traverse: %v", err)
+	}
+	if got := formatVisited(visited); got != "a:0 b:1 c:1" {
+		t.Errorf("visited %q, want %q", got, "a:0 b:1 c:1")
+	}
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// nativeDelete removes a document from the collection along with its index
+// entries and edges, and applies the OnDelete policies of the references pointing to it.
+func nativeDelete[T Document](c *Collection[T], txn *badger.Txn, docID string) error {
+	doc, found, err := nativeGet(c, txn, docID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil // Already deleted, e.g. earlier in a cascade
+	}
+
+	for field, value := range getIndexableFields(doc, c.Indexes) {
+		if err := c.removeIndexEntry(txn, field, value, docID); err != nil {
+			return err
+		}
+	}
+	if err := nativeUpdateReferenceKeys(txn, c.referenceKeys(doc, docID), nil); err != nil {
+		return err
+	}
+	if err := nativeRemoveNodeEdges(c, txn, docID); err != nil {
+		return err
+	}
+
+	key := c.docKey(docID)
+	if err := txn.Delete(key); err != nil {
+		return err
+	}
+	if err := nativeRecordVersion(c, txn, docID, Deleted, nil); err != nil {
+		return err
+	}
+
+	return nativeEnforceReferences(c, txn, docID)
+}
+
+// nativeDeleteOne deletes the first document matching the filter and returns
+// how many documents were deleted (0 or 1).
+func nativeDeleteOne[T Document](c *Collection[T], txn *badger.Txn, filter Filter) (int, error) {
+	result := nativeFindOne(c, txn, filter)
+	if !result.found {
+		return 0, nil
+	}
+
+	docID := documentID(result.doc)
+	if err := nativeDelete(c, txn, docID); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// nativeDeleteMany deletes every document matching the filter.
+func nativeDeleteMany[T Document](c *Collection[T], txn *badger.Txn, filter Filter) (int, error) {
+	results := nativeFind(c, txn, filter)
+
+	// Badger transactions are not safe for concurrent use, so the
+	// documents are deleted one after another
+	var deleted int
+	for _, doc := range results {
+		docID := documentID(doc)
+		if err := nativeDelete(c, txn, docID); err != nil {
+			return deleted, fmt.Errorf("failed to delete doc %s: %w", docID, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
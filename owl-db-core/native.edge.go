@@ -0,0 +1,212 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Adjacency key directions. Every edge is stored twice under the collection's
+// edge prefix: once as <out>from,label,to and once as <in>to,label,from, so
+// both its source and its target can list it with a prefix scan.
+const (
+	outEdgeKey = "o"
+	inEdgeKey  = "i"
+)
+
+// edgeKey builds the adjacency key of an edge as seen from id in direction.
+func (c *Collection[T]) edgeKey(direction, id, label, otherID string) []byte {
+	return []byte(c.edgePrefix + direction + id + keySeparator + label + keySeparator + otherID)
+}
+
+// edgeScanPrefix is the key prefix of the edges of id in direction, limited to
+// one label unless label is empty.
+func (c *Collection[T]) edgeScanPrefix(direction, id, label string) []byte {
+	if label == "" {
+		return []byte(c.edgePrefix + direction + id + keySeparator)
+	}
+	return []byte(c.edgePrefix + direction + id + keySeparator + label + keySeparator)
+}
+
+// checkEdgeLabel rejects labels the collection did not declare.
+func (c *Collection[T]) checkEdgeLabel(label string) error {
+	for _, edgeLabel := range c.EdgeLabels {
+		if edgeLabel == label {
+			return nil
+		}
+	}
+	return fmt.Errorf("edge label %q is not declared for collection %s", label, c.Name)
+}
+
+// nativeAddEdge stores both adjacency keys of an edge, replacing its
+// properties if it already exists. Both nodes must exist.
+func nativeAddEdge[T Document](c *Collection[T], txn *badger.Txn, fromID, label, toID string, props map[string]interface{}) error {
+	for _, docID := range []string{fromID, toID} {
+		_, found, err := nativeGet(c, txn, docID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("cannot add edge: document %s not found", docID)
+		}
+	}
+
+	if props == nil {
+		props = map[string]interface{}{}
+	}
+	serializedProps, err := bson.Marshal(props)
+	if err != nil {
+		return err
+	}
+
+	if err := txn.Set(c.edgeKey(outEdgeKey, fromID, label, toID), serializedProps); err != nil {
+		return err
+	}
+	return txn.Set(c.edgeKey(inEdgeKey, toID, label, fromID), serializedProps)
+}
+
+// nativeRemoveEdge deletes both adjacency keys of an edge. found is false if
+// the edge does not exist.
+func nativeRemoveEdge[T Document](c *Collection[T], txn *badger.Txn, fromID, label, toID string) (bool, error) {
+	outKey := c.edgeKey(outEdgeKey, fromID, label, toID)
+	_, err := txn.Get(outKey)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := txn.Delete(outKey); err != nil {
+		return false, err
+	}
+	return true, txn.Delete(c.edgeKey(inEdgeKey, toID, label, fromID))
+}
+
+// nativeEdges lists the edges of id in direction, optionally of one label.
+func nativeEdges[T Document](c *Collection[T], txn *badger.Txn, direction, id, label string) ([]Edge, error) {
+	var edges []Edge
+
+	iter := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer iter.Close()
+
+	nodePrefix := c.edgeScanPrefix(direction, id, "")
+	prefix := c.edgeScanPrefix(direction, id, label)
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		item := iter.Item()
+
+		// The key ends with the label and the other node's ID
+		rest := string(item.Key()[len(nodePrefix):])
+		edgeLabel, otherID, ok := strings.Cut(rest, keySeparator)
+		if !ok {
+			continue
+		}
+
+		edge := Edge{From: id, Label: edgeLabel, To: otherID}
+		if direction == inEdgeKey {
+			edge.From, edge.To = otherID, id
+		}
+
+		err := item.Value(func(val []byte) error {
+			return bson.Unmarshal(val, &edge.Props)
+		})
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, edge)
+	}
+	return edges, nil
+}
+
+// nativeRemoveNodeEdges deletes every edge into or out of a node, so deleting
+// a document leaves no dangling adjacency keys. The node's keys are scanned
+// even if c declares no labels, since another handle may have added edges.
+func nativeRemoveNodeEdges[T Document](c *Collection[T], txn *badger.Txn, docID string) error {
+	outEdges, err := nativeEdges(c, txn, outEdgeKey, docID, "")
+	if err != nil {
+		return err
+	}
+	inEdges, err := nativeEdges(c, txn, inEdgeKey, docID, "")
+	if err != nil {
+		return err
+	}
+
+	for _, edge := range append(outEdges, inEdges...) {
+		if _, err := nativeRemoveEdge(c, txn, edge.From, edge.Label, edge.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nativeAdjacentEdges returns the edges one hop from id in direction, of the
+// given labels (every label if none) and with properties matching
+// edgeFilter. With AnyDirection a self-loop is returned twice.
+func nativeAdjacentEdges[T Document](
+	c *Collection[T],
+	txn *badger.Txn,
+	id string,
+	labels []string,
+	direction Direction,
+	edgeFilter Filter,
+) ([]Edge, error) {
+	var keyDirections []string
+	switch direction {
+	case Outbound:
+		keyDirections = []string{outEdgeKey}
+	case Inbound:
+		keyDirections = []string{inEdgeKey}
+	default:
+		keyDirections = []string{outEdgeKey, inEdgeKey}
+	}
+	if len(labels) == 0 {
+		labels = []string{""}
+	}
+
+	var adjacent []Edge
+	for _, keyDirection := range keyDirections {
+		for _, label := range labels {
+			edges, err := nativeEdges(c, txn, keyDirection, id, label)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, edge := range edges {
+				if matchDocument(edge.Props, edgeFilter) {
+					adjacent = append(adjacent, edge)
+				}
+			}
+		}
+	}
+	return adjacent, nil
+}
+
+// nativeNeighbors returns the IDs of the nodes one hop from id along the
+// edges selected as in nativeAdjacentEdges, without duplicates.
+func nativeNeighbors[T Document](
+	c *Collection[T],
+	txn *badger.Txn,
+	id string,
+	labels []string,
+	direction Direction,
+	edgeFilter Filter,
+) ([]string, error) {
+	edges, err := nativeAdjacentEdges(c, txn, id, labels, direction, edgeFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	var neighbors []string
+	seen := make(map[string]bool)
+	for _, edge := range edges {
+		neighborID := edge.otherEnd(id)
+		if !seen[neighborID] {
+			seen[neighborID] = true
+			neighbors = append(neighbors, neighborID)
+		}
+	}
+	return neighbors, nil
+}
@@ -31,22 +31,22 @@ func nativeFind[T Document](
 	defer iter.Close()
 
 	// Scan through all documents in the collection
-	prefix := []byte(c.Name + "|")
+	prefix := []byte(c.nodePrefix)
 	batchSize := 100 // Size of each batch for parallel processing
-	batch := make([]*badger.Item, 0, batchSize)
+	batch := make([]rawDoc, 0, batchSize)
 
 	// Completion channel to signal when all batches are processed
 	completionCh := make(chan struct{})
 
 	// Channel to process batches in parallel
-	batchCh := make(chan []*badger.Item)
+	batchCh := make(chan []rawDoc)
 
 	// Goroutine to process batches
 	go func() {
 		for batch := range batchCh {
 			localResults := []map[string]interface{}{}
 			for _, item := range batch {
-				err := item.Value(func(val []byte) error {
+				err := func(val []byte) error {
 					var doc map[string]interface{}
 					if err := bson.Unmarshal(val, &doc); err != nil {
 						fmt.Printf("Deserialization error: %v\n", err)
@@ -54,7 +54,7 @@ func nativeFind[T Document](
 					}
 
 					// Check if this document has already been processed
-					docKey := string(item.Key())
+					docKey := string(item.key)
 					mu.Lock()
 					if _, found := processedDocs[docKey]; found {
 						mu.Unlock()
@@ -83,7 +83,7 @@ func nativeFind[T Document](
 						}
 					}
 					return nil
-				})
+				}(item.value)
 
 				if err != nil {
 					fmt.Printf("Error processing item: %v\n", err)
@@ -102,23 +102,23 @@ func nativeFind[T Document](
 
 	// Iterate over the documents
 	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
-		item := iter.Item()
+		item, err := copyItem(iter.Item())
+		if err != nil {
+			fmt.Printf("Error reading item: %v\n", err)
+			continue
+		}
 		batch = append(batch, item)
 
 		// When batch size is reached, send it for processing
 		if len(batch) == batchSize {
-			batchCopy := make([]*badger.Item, len(batch))
-			copy(batchCopy, batch)
-			batchCh <- batchCopy
-			batch = make([]*badger.Item, 0, batchSize) // Reset batch
+			batchCh <- batch
+			batch = make([]rawDoc, 0, batchSize) // Reset batch
 		}
 	}
 
 	// Process the remaining documents if any
 	if len(batch) > 0 {
-		batchCopy := make([]*badger.Item, len(batch))
-		copy(batchCopy, batch)
-		batchCh <- batchCopy
+		batchCh <- batch
 	}
 
 	// Close the batch channel to indicate no more batches
@@ -227,15 +227,14 @@ func nativeFindOne[T Document](
 	iter := txn.NewIterator(badger.DefaultIteratorOptions)
 	defer iter.Close()
 
-	prefix := []byte(c.Name + "|")
+	prefix := []byte(c.nodePrefix)
 	batchSize := 100 // Size of each batch for parallel processing
-	batch := make([]*badger.Item, 0, batchSize)
+	batch := make([]rawDoc, 0, batchSize)
 
 	var wg sync.WaitGroup
-	var mu sync.Mutex
 
 	// Function to process a batch of documents
-	processBatch := func(batch []*badger.Item) {
+	processBatch := func(batch []rawDoc) {
 		for _, item := range batch {
 			select {
 			case <-stopSearch:
@@ -244,22 +243,23 @@ func nativeFindOne[T Document](
 			default:
 			}
 
-			err := item.Value(func(val []byte) error {
+			err := func(val []byte) error {
 				var doc map[string]interface{}
 				if err := bson.Unmarshal(val, &doc); err != nil {
 					fmt.Printf("Deserialization error: %v\n", err)
 					return err
 				}
 
-				// Apply filters to the document
+				// Apply filters to the document; only the first match is kept
 				if matchDocument(doc, filter) {
-					mu.Lock()
-					foundDoc <- FoundDocStruct{doc: doc, found: true}
-					mu.Unlock()
+					select {
+					case foundDoc <- FoundDocStruct{doc: doc, found: true}:
+					default:
+					}
 				}
 
 				return nil
-			})
+			}(item.value)
 
 			if err != nil {
 				fmt.Printf("Error processing item: %v\n", err)
@@ -270,27 +270,31 @@ func nativeFindOne[T Document](
 
 	// Iterate over the collection
 	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
-		item := iter.Item()
+		item, err := copyItem(iter.Item())
+		if err != nil {
+			fmt.Printf("Error reading item: %v\n", err)
+			continue
+		}
 		batch = append(batch, item)
 
 		// Process batch when batch size is reached
 		if len(batch) == batchSize {
 			wg.Add(1)
-			go func() {
+			go func(batch []rawDoc) {
 				defer wg.Done()
 				processBatch(batch)
-			}()
-			batch = make([]*badger.Item, 0, batchSize) // Reset batch
+			}(batch)
+			batch = make([]rawDoc, 0, batchSize) // Reset batch
 		}
 	}
 
 	// Process remaining documents in the batch
 	if len(batch) > 0 {
 		wg.Add(1)
-		go func() {
+		go func(batch []rawDoc) {
 			defer wg.Done()
 			processBatch(batch)
-		}()
+		}(batch)
 	}
 
 	// Wait for all goroutines to finish
@@ -305,3 +309,19 @@ func nativeFindOne[T Document](
 
 	return result
 }
+
+// rawDoc is a key/value pair copied out of an iterator. Iterator items are
+// only valid until the next call to Next, so batches processed by other
+// goroutines must hold copies.
+type rawDoc struct {
+	key   []byte
+	value []byte
+}
+
+func copyItem(item *badger.Item) (rawDoc, error) {
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return rawDoc{}, err
+	}
+	return rawDoc{key: item.KeyCopy(nil), value: value}, nil
+}
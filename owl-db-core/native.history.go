@@ -0,0 +1,130 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// historyRecord is the stored form of a Version. Versions are keyed by a
+// per-document sequence number, so their order doesn't depend on the clock.
+type historyRecord struct {
+	At     int64    `bson:"at"` // Unix nanoseconds, never before the previous version
+	Change Change   `bson:"change"`
+	Doc    bson.Raw `bson:"doc,omitempty"`
+}
+
+// historyDocPrefix is the prefix of every version of a document.
+func (c *Collection[T]) historyDocPrefix(docID string) []byte {
+	return []byte(c.historyPrefix + docID + keySeparator)
+}
+
+// nativeLastVersion returns the sequence number and time of the latest kept
+// version of a document, or zeros if it has none.
+func nativeLastVersion[T Document](c *Collection[T], txn *badger.Txn, docID string) (uint64, int64, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+
+	prefix := c.historyDocPrefix(docID)
+	iter.Seek(append(bytes.Clone(prefix), 0xff))
+	if !iter.ValidForPrefix(prefix) {
+		return 0, 0, nil
+	}
+
+	item := iter.Item()
+	seq := binary.BigEndian.Uint64(item.Key()[len(prefix):])
+	var record historyRecord
+	err := item.Value(func(val []byte) error {
+		return bson.Unmarshal(val, &record)
+	})
+	return seq, record.At, err
+}
+
+// nativeRecordVersion records a version of a document written in txn, if the
+// collection keeps versions. doc is the document's BSON after the change, nil
+// for a deletion.
+func nativeRecordVersion[T Document](c *Collection[T], txn *badger.Txn, docID string, change Change, doc []byte) error {
+	if c.KeepVersions == 0 {
+		return nil
+	}
+
+	seq, at, err := nativeLastVersion(c, txn, docID)
+	if err != nil {
+		return err
+	}
+	record, err := bson.Marshal(historyRecord{
+		At:     max(time.Now().UnixNano(), at),
+		Change: change,
+		Doc:    doc,
+	})
+	if err != nil {
+		return err
+	}
+
+	key := binary.BigEndian.AppendUint64(c.historyDocPrefix(docID), seq+1)
+	if err := txn.Set(key, record); err != nil {
+		return err
+	}
+	return nativePruneVersions(c, txn, docID)
+}
+
+// nativePruneVersions deletes the oldest versions of a document beyond the
+// current one and c.KeepVersions prior ones.
+func nativePruneVersions[T Document](c *Collection[T], txn *badger.Txn, docID string) error {
+	if c.KeepVersions == 0 {
+		return nil
+	}
+
+	var keys [][]byte
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	iter := txn.NewIterator(opts)
+	prefix := c.historyDocPrefix(docID)
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		keys = append(keys, iter.Item().KeyCopy(nil))
+	}
+	iter.Close()
+
+	for len(keys) > c.KeepVersions+1 {
+		if err := txn.Delete(keys[0]); err != nil {
+			return err
+		}
+		keys = keys[1:]
+	}
+	return nil
+}
+
+// nativeVersions reads the kept versions of a document, oldest first.
+func nativeVersions[T Document](c *Collection[T], txn *badger.Txn, docID string) ([]Version, error) {
+	var versions []Version
+
+	iter := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer iter.Close()
+
+	prefix := c.historyDocPrefix(docID)
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		var version Version
+		err := iter.Item().Value(func(val []byte) error {
+			var record historyRecord
+			if err := bson.Unmarshal(val, &record); err != nil {
+				return err
+			}
+			version.At = time.Unix(0, record.At)
+			version.Change = record.Change
+			if record.Doc != nil {
+				return bson.Unmarshal(record.Doc, &version.Doc)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
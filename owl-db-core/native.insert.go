@@ -0,0 +1,91 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// nativeInsert stores a document and its index entries, generating an ID if
+// the document has none.
+func nativeInsert[T Document](c *Collection[T], txn *badger.Txn, doc T) error {
+	entries, err := insertEntries(c, doc)
+	if err != nil {
+		return err
+	}
+	return nativeWriteInsert(c, txn, doc.GetID(), entries)
+}
+
+// nativeWriteInsert writes the entries of a new document prepared by
+// insertEntries, then records its first kept version after any versions of
+// an earlier document with the same ID. In a versioned
+// collection it fails with ErrDuplicateID, before writing anything, if the ID
+// is taken, since overwriting would restart the document's version at 1.
+func nativeWriteInsert[T Document](c *Collection[T], txn *badger.Txn, docID string, entries []*badger.Entry) error {
+	if c.Versioning {
+		_, err := txn.Get(c.docKey(docID))
+		if err == nil {
+			return fmt.Errorf("%w: %s", ErrDuplicateID, docID)
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+	}
+	// The document's own entry comes first
+	return nativeRecordVersion(c, txn, docID, Inserted, entries[0].Value)
+}
+
+// insertEntries prepares a new document for writing: it assigns an ID if
+// needed, sets the creation time and encodes the document key followed by its
+// index keys and reference keys.
+func insertEntries[T Document](c *Collection[T], doc T) ([]*badger.Entry, error) {
+	// Check if the document already has an ID
+	if doc.GetID() == "" {
+		doc.SetID(primitive.NewObjectID().Hex())
+	}
+	if c.Timestamp {
+		doc.SetCreatedAt()
+	}
+
+	docID := doc.GetID()
+	key := c.docKey(docID)
+
+	// Serialize the document
+	serializedDoc, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if c.Versioning {
+		serializedDoc, err = stampVersion(serializedDoc, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries := []*badger.Entry{badger.NewEntry(key, serializedDoc)}
+
+	// Get the indexable fields
+	indexableFields := getIndexableFields(doc, c.Indexes)
+
+	// Add index entries for the indexable fields
+	for field, value := range indexableFields {
+		indexKey := c.indexKey(field, value, docID)
+		entries = append(entries, badger.NewEntry(indexKey, []byte(docID)))
+	}
+
+	// Record the documents this one references
+	for referenceKey := range c.referenceKeys(doc, docID) {
+		entries = append(entries, badger.NewEntry([]byte(referenceKey), nil))
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,338 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// ErrReferenced is returned when deleting a document that a Restrict
+// reference still points to.
+var ErrReferenced = errors.New("document is still referenced")
+
+// OnDelete is what happens to referencing documents when the document they
+// point to is deleted.
+type OnDelete int
+
+const (
+	Restrict OnDelete = iota // Refuse to delete a referenced document
+	Cascade                  // Delete the referencing documents too
+	SetNull                  // Set the referencing field to null
+)
+
+// Reference declares that Field holds the ID of a document in Collection.
+type Reference struct {
+	Field      string // Dotted path of the field holding the referenced ID
+	Collection string // Name of the referenced collection
+	OnDelete   OnDelete
+}
+
+// referenceRule is a reference as seen from the referenced collection. The
+// referencing collection's type is unknown there, so the rule carries
+// closures bound to it. Rules loaded from the catalog have no closures until
+// a handle on the referencing collection is created.
+type referenceRule struct {
+	source   string // Name and ID of the referencing collection
+	sourceID uint64
+	field    string
+	onDelete OnDelete
+	remove   func(txn *badger.Txn, docID string) error
+	nullify  func(txn *badger.Txn, docID string) error
+}
+
+// registerReferences makes the references of c known to the collections they
+// point to, replacing rules from an earlier handle on the same collection.
+// The rules are stored in the catalog, so they are enforced from the moment
+// the database is opened again, before any handle on c exists. A reference
+// stays enforced until its collection is dropped, even for handles that
+// don't declare it.
+func registerReferences[T Document](c *Collection[T]) error {
+	if len(c.References) == 0 {
+		return nil
+	}
+
+	c.db.referencesMu.Lock()
+	defer c.db.referencesMu.Unlock()
+
+	byTarget := c.db.references
+
+	for i, reference := range c.References {
+		field := reference.Field
+
+		rule := referenceRule{
+			source:   c.Name,
+			sourceID: c.id,
+			field:    reference.Field,
+			onDelete: reference.OnDelete,
+			remove: func(txn *badger.Txn, docID string) error {
+				return nativeDelete(c, txn, docID)
+			},
+			nullify: func(txn *badger.Txn, docID string) error {
+				doc, found, err := nativeGet(c, txn, docID)
+				if err != nil || !found {
+					return err
+				}
+				update, err := compileUpdateSpec(Update{"$set": map[string]interface{}{field: nil}})
+				if err != nil {
+					return err
+				}
+				return nativeUpdate(c, txn, doc, docID, update, newUpdateContext(nil, UpdateOptions{}))
+			},
+		}
+
+		target := c.refTargets[i]
+		rules := byTarget[target]
+		existing := -1
+		for j := range rules {
+			if rules[j].sourceID == rule.sourceID && rules[j].field == rule.field {
+				existing = j
+			}
+		}
+		if existing < 0 || rules[existing].onDelete != rule.onDelete {
+			if err := c.db.storeReferenceRule(target, rule); err != nil {
+				return fmt.Errorf("failed to register reference %s.%s: %w", c.Name, field, err)
+			}
+		}
+
+		if existing >= 0 {
+			rules[existing] = rule
+		} else {
+			rules = append(rules, rule)
+		}
+		byTarget[target] = rules
+	}
+	return nil
+}
+
+// referenceRules returns the rules of references pointing into c.
+func (c *Collection[T]) referenceRules() []referenceRule {
+	c.db.referencesMu.RLock()
+	defer c.db.referencesMu.RUnlock()
+	return c.db.references[c.id]
+}
+
+// referenceKey is the reverse-reference index key recording that document
+// sourceID of collection source points to targetID of collection target
+// through field.
+func referenceKey(target uint64, targetID string, source uint64, field, sourceID string) string {
+	return collectionPrefix(refPrefix, target) + targetID + keySeparator +
+		string(encodeCollectionID(source)) + field + keySeparator + sourceID
+}
+
+// referenceKeys returns the reverse-reference keys of a document of c. Only
+// non-empty string IDs are references; null and missing fields are not.
+func (c *Collection[T]) referenceKeys(doc interface{}, docID string) map[string]struct{} {
+	keys := make(map[string]struct{})
+	if len(c.References) == 0 {
+		return keys
+	}
+
+	fields := make([]string, len(c.References))
+	for i, reference := range c.References {
+		fields[i] = reference.Field
+	}
+	values := getIndexableFields(doc, fields)
+
+	for i, reference := range c.References {
+		targetID, ok := values[reference.Field].(string)
+		if ok && targetID != "" {
+			keys[referenceKey(c.refTargets[i], targetID, c.id, reference.Field, docID)] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// nativeUpdateReferenceKeys replaces the old reverse-reference keys of a
+// document with the new ones.
+func nativeUpdateReferenceKeys(txn *badger.Txn, oldKeys, newKeys map[string]struct{}) error {
+	for key := range oldKeys {
+		if _, kept := newKeys[key]; !kept {
+			if err := txn.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+	}
+	for key := range newKeys {
+		if _, existed := oldKeys[key]; !existed {
+			if err := txn.Set([]byte(key), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nativeEnforceReferences applies the OnDelete policy of every reference to a
+// document of c that is being deleted.
+func nativeEnforceReferences[T Document](c *Collection[T], txn *badger.Txn, docID string) error {
+	for _, rule := range c.referenceRules() {
+		dependents, err := referencingIDs(txn, c.id, docID, rule)
+		if err != nil {
+			return err
+		}
+		if len(dependents) == 0 {
+			continue
+		}
+
+		if rule.onDelete != Restrict && rule.remove == nil {
+			// Loaded from the catalog, and no handle has bound its closures yet
+			return fmt.Errorf("cannot delete %s %s: %w by %d document(s) in %s.%s; create a handle on %s with NewCollection to apply its OnDelete policy",
+				c.Name, docID, ErrReferenced, len(dependents), rule.source, rule.field, rule.source)
+		}
+
+		switch rule.onDelete {
+		case Restrict:
+			return fmt.Errorf("cannot delete %s %s: %w by %d document(s) in %s.%s",
+				c.Name, docID, ErrReferenced, len(dependents), rule.source, rule.field)
+		case Cascade:
+			for _, dependentID := range dependents {
+				if err := rule.remove(txn, dependentID); err != nil {
+					return fmt.Errorf("cascading delete to %s %s: %w", rule.source, dependentID, err)
+				}
+			}
+		case SetNull:
+			for _, dependentID := range dependents {
+				if err := rule.nullify(txn, dependentID); err != nil {
+					return fmt.Errorf("clearing %s.%s of %s: %w", rule.source, rule.field, dependentID, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// referencingIDs lists the documents pointing to targetID through rule.
+func referencingIDs(txn *badger.Txn, target uint64, targetID string, rule referenceRule) ([]string, error) {
+	var ids []string
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+
+	prefix := []byte(referenceKey(target, targetID, rule.sourceID, rule.field, ""))
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		ids = append(ids, string(iter.Item().Key()[len(prefix):]))
+	}
+	return ids, nil
+}
+
+// referenceRuleKey is the catalog key of a reference from field of
+// collection source to collection target.
+func referenceRuleKey(target, source uint64, field string) []byte {
+	return []byte(collectionPrefix(referenceRulePrefix, target) + string(encodeCollectionID(source)) + field)
+}
+
+// storeReferenceRule records a reference rule in the catalog.
+func (db *DB) storeReferenceRule(target uint64, rule referenceRule) error {
+	return withRetry(context.Background(), DefaultRetryOptions, func() error {
+		return db.Badger.Update(func(txn *badger.Txn) error {
+			return txn.Set(referenceRuleKey(target, rule.sourceID, rule.field), []byte{byte(rule.onDelete)})
+		})
+	})
+}
+
+// loadReferenceRules reads the reference rules of the catalog that aren't in
+// memory yet. They can restrict deletes at once; cascading and nulling need
+// the closures of a handle on the referencing collection.
+func (db *DB) loadReferenceRules() error {
+	db.catalogMu.Lock()
+	names := make(map[uint64]string, len(db.catalog))
+	for name, id := range db.catalog {
+		names[id] = name
+	}
+	db.catalogMu.Unlock()
+
+	db.referencesMu.Lock()
+	defer db.referencesMu.Unlock()
+
+	return db.Badger.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+
+		prefix := []byte(referenceRulePrefix)
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			item := iter.Item()
+			key := item.Key()[len(prefix):]
+			if len(key) < 16 {
+				return fmt.Errorf("corrupt reference rule %q", item.Key())
+			}
+			target := binary.BigEndian.Uint64(key[:8])
+			rule := referenceRule{
+				sourceID: binary.BigEndian.Uint64(key[8:16]),
+				field:    string(key[16:]),
+			}
+			rule.source = names[rule.sourceID]
+
+			err := item.Value(func(val []byte) error {
+				if len(val) != 1 {
+					return fmt.Errorf("corrupt reference rule %s.%s", rule.source, rule.field)
+				}
+				rule.onDelete = OnDelete(val[0])
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if !hasReferenceRule(db.references[target], rule) {
+				db.references[target] = append(db.references[target], rule)
+			}
+		}
+		return nil
+	})
+}
+
+// hasReferenceRule reports whether rules hold a rule for the same field of
+// the same collection as rule.
+func hasReferenceRule(rules []referenceRule, rule referenceRule) bool {
+	for _, existing := range rules {
+		if existing.sourceID == rule.sourceID && existing.field == rule.field {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteReferenceRules deletes the stored rules of the references from and
+// to a dropped collection.
+func deleteReferenceRules(txn *badger.Txn, id uint64) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+
+	var keys [][]byte
+	prefix := []byte(referenceRulePrefix)
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		key := iter.Item().Key()[len(prefix):]
+		if len(key) >= 16 && (binary.BigEndian.Uint64(key[:8]) == id || binary.BigEndian.Uint64(key[8:16]) == id) {
+			keys = append(keys, iter.Item().KeyCopy(nil))
+		}
+	}
+	iter.Close()
+
+	for _, key := range keys {
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forgetReferenceRules removes the rules of the references from and to a
+// dropped collection from memory. The caller holds referencesMu.
+func (db *DB) forgetReferenceRules(id uint64) {
+	delete(db.references, id)
+	for target, rules := range db.references {
+		kept := rules[:0]
+		for _, rule := range rules {
+			if rule.sourceID != id {
+				kept = append(kept, rule)
+			}
+		}
+		db.references[target] = kept
+	}
+}
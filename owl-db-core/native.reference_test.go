@@ -0,0 +1,69 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+)
+
+type order struct {
+	ID        string `bson:"_id"`
+	ProductID string `bson:"productId"`
+}
+
+func (o *order) GetID() string   { return o.ID }
+func (o *order) SetID(id string) { o.ID = id }
+func (o *order) SetCreatedAt()   {}
+func (o *order) SetUpdatedAt()   {}
+
+// openOrders opens the database at dir with orders referencing products.
+func openOrders(t *testing.T, dir string, onDelete core.OnDelete) (*core.DB, core.CollectionOptions) {
+	t.Helper()
+
+	db, err := core.Open(dir, core.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db, core.CollectionOptions{References: []core.Reference{
+		{Field: "productId", Collection: "products", OnDelete: onDelete},
+	}}
+}
+
+func TestReferencesEnforcedAfterReopen(t *testing.T) {
+	for _, onDelete := range []core.OnDelete{core.Restrict, core.Cascade} {
+		dir := t.TempDir()
+
+		db, options := openOrders(t, dir, onDelete)
+		if err := core.NewCollection[*product](db, "products").Insert(&product{ID: "p1", Name: "lamp"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := core.NewCollection[*order](db, "orders", options).Insert(&order{ID: "o1", ProductID: "p1"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// No handle on orders exists yet after reopening
+		db, options = openOrders(t, dir, onDelete)
+		products := core.NewCollection[*product](db, "products")
+		if err := products.DeleteByID("p1"); !errors.Is(err, core.ErrReferenced) {
+			t.Errorf("OnDelete %d: DeleteByID error = %v, want ErrReferenced", onDelete, err)
+		}
+		if onDelete != core.Cascade {
+			continue
+		}
+
+		// A handle binds the cascade
+		orders := core.NewCollection[*order](db, "orders", options)
+		if err := products.DeleteByID("p1"); err != nil {
+			t.Fatalf("DeleteByID with a handle on orders: %v", err)
+		}
+		if _, err := orders.FindByID("o1"); err == nil {
+			t.Error("referencing order not deleted by the cascade")
+		}
+	}
+}
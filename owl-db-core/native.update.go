@@ -2,10 +2,12 @@ package core
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	badger "github.com/dgraph-io/badger/v4"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func nativeUpdate[T Document](
@@ -13,17 +15,36 @@ func nativeUpdate[T Document](
 	txn *badger.Txn,
 	doc map[string]interface{},
 	docID string,
-	update Update,
+	update compiledUpdate,
+	ctx updateContext,
 ) error {
 
+	err := c.checkVersion(doc, ctx.version)
+	if err != nil {
+		return err
+	}
+	version := documentVersion(doc)
+
 	oldIndexableFields := getIndexableFields(doc, c.Indexes)
+	oldReferenceKeys := c.referenceKeys(doc, docID)
 
-	// Apply the update (assume `applyUpdate` function handles it correctly)
-	err := applyUpdate(doc, update)
+	// Apply the update operators or pipeline
+	err = update.apply(doc, ctx.bind(doc))
 	if err != nil {
 		return err
 	}
 
+	if c.Timestamp {
+		doc["updatedAt"] = time.Now()
+	}
+	if c.Versioning {
+		doc[versionField] = version + 1
+	}
+
+	if ctx.dryRun {
+		return nil // Leave the stored document untouched
+	}
+
 	newIndexableFields := getIndexableFields(doc, c.Indexes)
 
 	// Handle index updates
@@ -31,7 +52,7 @@ func nativeUpdate[T Document](
 		newValue, exists := newIndexableFields[field]
 		if !exists || newValue != oldValue {
 			// Remove old index entry
-			err := removeIndexEntry(txn, field, oldValue, docID)
+			err := c.removeIndexEntry(txn, field, oldValue, docID)
 			if err != nil {
 				return err
 			}
@@ -42,15 +63,16 @@ func nativeUpdate[T Document](
 		oldValue, exists := oldIndexableFields[field]
 		if !exists || newValue != oldValue {
 			// Add new index entry
-			err := addIndexEntry(txn, field, newValue, docID)
+			err := c.addIndexEntry(txn, field, newValue, docID)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
-	if c.Timestamp {
-		doc["updatedAt"] = time.Now()
+	err = nativeUpdateReferenceKeys(txn, oldReferenceKeys, c.referenceKeys(doc, docID))
+	if err != nil {
+		return err
 	}
 
 	// Serialize and write back the updated document
@@ -58,11 +80,185 @@ func nativeUpdate[T Document](
 	if err != nil {
 		return err
 	}
-	key := fmt.Sprintf("%s|%s", c.Name, docID)
-	err = txn.Set([]byte(key), updatedData)
+	key := c.docKey(docID)
+	err = txn.Set(key, updatedData)
 	if err != nil {
 		return err
 	}
 
+	return nativeRecordVersion(c, txn, docID, Updated, updatedData)
+}
+
+// nativeUpdateOne updates the first document matching the filter, or
+// upserts one if requested. A result with no match and no upsert means
+// nothing matched.
+func nativeUpdateOne[T Document](
+	c *Collection[T],
+	txn *badger.Txn,
+	filter Filter,
+	update compiledUpdate,
+	options UpdateOptions,
+) (UpdateResult, error) {
+	var result UpdateResult
+
+	found := nativeFindOne(c, txn, filter)
+	if !found.found {
+		if options.Upsert {
+			doc, err := nativeUpsert(c, txn, filter, update, options)
+			if err != nil {
+				return UpdateResult{}, err
+			}
+			result.upserted(doc, options)
+		}
+		return result, nil
+	}
+
+	doc := found.doc
+	docID := documentID(found.doc)
+
+	err := nativeUpdate(c, txn, doc, docID, update, newUpdateContext(filter, options))
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	result.matched(doc, options)
+	return result, nil
+}
+
+// nativeUpdateMany updates every document matching the filter, or upserts
+// one if nothing matches and it was requested.
+func nativeUpdateMany[T Document](
+	c *Collection[T],
+	txn *badger.Txn,
+	filter Filter,
+	update compiledUpdate,
+	options UpdateOptions,
+) (UpdateResult, error) {
+	var result UpdateResult
+	results := nativeFind(c, txn, filter)
+
+	if len(results) == 0 {
+		if options.Upsert {
+			doc, err := nativeUpsert(c, txn, filter, update, options)
+			if err != nil {
+				return UpdateResult{}, err
+			}
+			result.upserted(doc, options)
+		}
+		return result, nil // No matching documents
+	}
+
+	// Badger transactions are not safe for concurrent use, so the
+	// documents are updated one after another
+	for _, doc := range results {
+		docID := documentID(doc)
+		err := nativeUpdate(c, txn, doc, docID, update, newUpdateContext(filter, options))
+		if err != nil {
+			return UpdateResult{}, fmt.Errorf("failed to update doc %s: %w", docID, err)
+		}
+		result.matched(doc, options)
+	}
+	return result, nil
+}
+
+// nativeUpsert inserts a new document built from the equality conditions of
+// the filter and then the update (including $setOnInsert), as MongoDB does
+// when an upsert matches nothing.
+func nativeUpsert[T Document](
+	c *Collection[T],
+	txn *badger.Txn,
+	filter Filter,
+	update compiledUpdate,
+	options UpdateOptions,
+) (map[string]interface{}, error) {
+	doc := make(map[string]interface{})
+	err := seedFromFilter(doc, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := newUpdateContext(filter, options)
+	ctx.inserting = true
+	err = c.checkVersion(doc, ctx.version)
+	if err != nil {
+		return nil, err
+	}
+	err = update.apply(doc, ctx.bind(doc))
+	if err != nil {
+		return nil, err
+	}
+
+	docID := documentID(doc)
+	if docID == "" {
+		docID = primitive.NewObjectID().Hex()
+		doc["_id"] = docID
+	}
+
+	key := c.docKey(docID)
+	if _, err := txn.Get(key); err == nil {
+		return nil, fmt.Errorf("cannot upsert: document %s already exists", docID)
+	}
+
+	if c.Timestamp {
+		now := time.Now()
+		doc["createdAt"] = now
+		doc["updatedAt"] = now
+	}
+	if c.Versioning {
+		doc[versionField] = int64(1)
+	}
+
+	if ctx.dryRun {
+		return doc, nil
+	}
+
+	// Serialize and store the new document
+	serializedDoc, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := txn.Set(key, serializedDoc); err != nil {
+		return nil, err
+	}
+	if err := nativeRecordVersion(c, txn, docID, Inserted, serializedDoc); err != nil {
+		return nil, err
+	}
+
+	// Update indexes for the indexable fields
+	for field, value := range getIndexableFields(doc, c.Indexes) {
+		indexKey := c.indexKey(field, value, docID)
+		if err := txn.Set(indexKey, []byte(docID)); err != nil {
+			return nil, err
+		}
+	}
+	err = nativeUpdateReferenceKeys(txn, nil, c.referenceKeys(doc, docID))
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// seedFromFilter copies the plain equality conditions of a filter into doc.
+func seedFromFilter(doc map[string]interface{}, filter Filter) error {
+	for field, value := range filter {
+		if field == "$and" {
+			subFilters, _ := value.([]Filter)
+			for _, subFilter := range subFilters {
+				if err := seedFromFilter(doc, subFilter); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(field, "$") {
+			continue // $or / $nor don't pin a single value
+		}
+		if _, isOperator := value.(Filter); isOperator {
+			continue
+		}
+		if err := updateNestedField(doc, field, value); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,103 @@
+// Package testutil opens throwaway owl-db databases for tests.
+//
+//	func TestOrders(t *testing.T) {
+//		db := testutil.OpenDB(t)
+//		products := core.NewCollection[*Product](db, "products")
+//		testutil.Seed(t, products, "testdata/products.json")
+//		...
+//	}
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OpenDB opens an empty in-memory database that is closed when the test
+// finishes. opts may tune it further; InMemory is always set.
+func OpenDB(tb testing.TB, opts ...core.Options) *core.DB {
+	tb.Helper()
+
+	var options core.Options
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	options.InMemory = true
+	if options.Logger == nil {
+		options.Logger = testLogger{tb}
+	}
+
+	db, err := core.Open("", options)
+	if err != nil {
+		tb.Fatalf("failed to open in-memory database: %v", err)
+	}
+	tb.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			tb.Errorf("failed to close in-memory database: %v", err)
+		}
+	})
+	return db
+}
+
+// Seed inserts the documents of a JSON fixture into c and returns them. The
+// file holds an array of documents in MongoDB Extended JSON, so plain JSON
+// works and {"$oid": ...} or {"$date": ...} values keep their BSON types.
+// Fields are matched by the documents' bson tags.
+func Seed[T core.Document](tb testing.TB, c *core.Collection[T], path string) []T {
+	tb.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("failed to read fixture: %v", err)
+	}
+
+	docs, err := decodeFixture[T](data)
+	if err != nil {
+		tb.Fatalf("failed to decode fixture %s: %v", path, err)
+	}
+
+	if _, err := c.InsertMany(docs); err != nil {
+		tb.Fatalf("failed to seed collection %s from %s: %v", c.Name, path, err)
+	}
+	return docs
+}
+
+// decodeFixture decodes a JSON array of documents into new values of T.
+func decodeFixture[T core.Document](data []byte) ([]T, error) {
+	var rawDocs []json.RawMessage
+	if err := json.Unmarshal(data, &rawDocs); err != nil {
+		return nil, fmt.Errorf("expected an array of documents: %w", err)
+	}
+
+	docs := make([]T, len(rawDocs))
+	for i, rawDoc := range rawDocs {
+		var doc T
+		if err := bson.UnmarshalExtJSON(rawDoc, false, &doc); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		docs[i] = doc
+	}
+	return docs, nil
+}
+
+// testLogger reports Badger's warnings and errors through the test and drops
+// its informational messages.
+type testLogger struct {
+	tb testing.TB
+}
+
+func (l testLogger) Errorf(format string, args ...interface{}) {
+	l.tb.Logf("badger: "+format, args...)
+}
+
+func (l testLogger) Warningf(format string, args ...interface{}) {
+	l.tb.Logf("badger: "+format, args...)
+}
+
+func (testLogger) Infof(string, ...interface{})  {}
+func (testLogger) Debugf(string, ...interface{}) {}
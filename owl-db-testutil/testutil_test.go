@@ -0,0 +1,49 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "github.com/TimiBolu/owl-db/owl-db-core"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type item struct {
+	ID      string             `bson:"_id"`
+	Name    string             `bson:"name"`
+	Created primitive.DateTime `bson:"created"`
+}
+
+func (i *item) GetID() string   { return i.ID }
+func (i *item) SetID(id string) { i.ID = id }
+func (i *item) SetCreatedAt()   {}
+func (i *item) SetUpdatedAt()   {}
+
+func TestSeed(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "items.json")
+	data := `[{"_id": "a", "name": "lamp", "created": {"$date": "2024-05-01T00:00:00Z"}}, {"name": "desk"}]`
+	if err := os.WriteFile(fixture, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	items := core.NewCollection[*item](OpenDB(t), "items")
+	seeded := Seed(t, items, fixture)
+	if len(seeded) != 2 || seeded[0].ID != "a" || seeded[1].ID == "" {
+		t.Fatalf("seeded %+v, want a and a generated ID", seeded)
+	}
+
+	doc, err := items.FindByID("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created, ok := doc["created"].(primitive.DateTime); !ok || created.Time().Year() != 2024 {
+		t.Errorf("created = %#v, want the fixture's date", doc["created"])
+	}
+}
+
+func TestOpenDBIsEmpty(t *testing.T) {
+	if names := OpenDB(t).CollectionNames(); len(names) != 0 {
+		t.Errorf("collections %v, want none", names)
+	}
+}